@@ -1,13 +1,18 @@
 package claimgen
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
 
 	rewardsCoordinator "github.com/Layr-Labs/eigenlayer-contracts/pkg/bindings/IRewardsCoordinator"
 
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/distribution"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/utils"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/utils"
 
 	gethcommon "github.com/ethereum/go-ethereum/common"
 
@@ -225,3 +230,237 @@ func (c *Claimgen) GenerateClaimProofForEarner(
 
 	return accountTree, merkleClaim, err
 }
+
+// GenerateClaimProof behaves like GenerateClaimProofForEarner but returns the claim in the
+// RewardsCoordinator CLI JSON format. It is built on Distribution.AccountProof and Distribution.TokenProof.
+//
+// The claim holds one earner proof and a token index, leaf and proof for each of the tokens, in the order
+// given, so all of an earner's tokens can be claimed in one call. ErrTokenIndexNotFound names every token
+// the earner does not hold.
+func (c *Claimgen) GenerateClaimProof(
+	earner gethcommon.Address,
+	tokens []gethcommon.Address,
+	rootIndex uint32,
+) (*ClaimProof, error) {
+	_, tokenTrees, err := c.Distribution.Merklize()
+	if err != nil {
+		return nil, err
+	}
+
+	claim, err := generateClaimProof(merklizedDistribution{c.Distribution, tokenTrees}, earner, tokens, rootIndex)
+	if err != nil {
+		return nil, err
+	}
+	claim.Snapshot = c.Distribution.Snapshot
+	claim.CalculationEndTimestamp = c.Distribution.CalculationEndTimestamp
+	return claim, nil
+}
+
+// GenerateFrozenClaimProof behaves like GenerateClaimProof for a frozen distribution. Unlike a Claimgen,
+// which may merklize its distribution, it only reads from the frozen distribution, so it is safe to call
+// from many goroutines at once; the claims do not alias the distribution.
+func GenerateFrozenClaimProof(
+	frozen *distribution.ReadOnlyDistribution,
+	earner gethcommon.Address,
+	tokens []gethcommon.Address,
+	rootIndex uint32,
+) (*ClaimProof, error) {
+	claim, err := generateClaimProof(frozen, earner, tokens, rootIndex)
+	if err != nil {
+		return nil, err
+	}
+	claim.Snapshot = frozen.Snapshot()
+	claim.CalculationEndTimestamp = frozen.CalculationEndTimestamp()
+	return claim, nil
+}
+
+// claimSource is the merklized distribution a claim is read from
+type claimSource interface {
+	AccountProof(earner gethcommon.Address) ([][]byte, uint64, error)
+	TokenProof(earner, token gethcommon.Address) ([][]byte, uint64, error)
+	Get(earner, token gethcommon.Address) (*big.Int, bool)
+	EarnerTokenRoot(earner gethcommon.Address) ([]byte, bool)
+}
+
+// merklizedDistribution is a claimSource for a distribution along with its token trees
+type merklizedDistribution struct {
+	*distribution.Distribution
+	tokenTrees map[gethcommon.Address]*merkletree.MerkleTree
+}
+
+func (m merklizedDistribution) EarnerTokenRoot(earner gethcommon.Address) ([]byte, bool) {
+	tokenTree, found := m.tokenTrees[earner]
+	if !found {
+		return nil, false
+	}
+	return tokenTree.Root(), true
+}
+
+// generateClaimProof builds the claim of an earner's tokens, leaving the snapshot and calculation end
+// timestamp to the caller
+func generateClaimProof(
+	source claimSource,
+	earner gethcommon.Address,
+	tokens []gethcommon.Address,
+	rootIndex uint32,
+) (*ClaimProof, error) {
+	earnerTreeProof, earnerIndex, err := source.AccountProof(earner)
+	if errors.Is(err, distribution.ErrEarnerNotFound) {
+		return nil, fmt.Errorf("%w for earner %s", ErrEarnerIndexNotFound, earner.Hex())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make([]string, 0)
+	for _, token := range tokens {
+		if _, found := source.Get(earner, token); !found {
+			missing = append(missing, token.Hex())
+		}
+	}
+	if len(missing) == 1 {
+		return nil, fmt.Errorf("%w for token %s and earner %s", ErrTokenIndexNotFound, missing[0], earner.Hex())
+	}
+	if len(missing) > 1 {
+		return nil, fmt.Errorf("%w for tokens %s and earner %s", ErrTokenIndexNotFound, strings.Join(missing, ", "), earner.Hex())
+	}
+
+	tokenIndices := make([]uint32, 0, len(tokens))
+	tokenTreeProofs := make([][]byte, 0, len(tokens))
+	tokenLeaves := make([]ClaimProofTokenLeaf, 0, len(tokens))
+	for _, token := range tokens {
+		tokenTreeProof, tokenIndex, err := source.TokenProof(earner, token)
+		if err != nil {
+			return nil, err
+		}
+		amount, _ := source.Get(earner, token)
+
+		tokenIndices = append(tokenIndices, uint32(tokenIndex))
+		tokenTreeProofs = append(tokenTreeProofs, flattenHashes(tokenTreeProof))
+		tokenLeaves = append(tokenLeaves, ClaimProofTokenLeaf{
+			Token:              token,
+			CumulativeEarnings: amount,
+		})
+	}
+
+	var earnerTokenRoot [32]byte
+	root, _ := source.EarnerTokenRoot(earner)
+	copy(earnerTokenRoot[:], root)
+
+	return &ClaimProof{
+		RootIndex:       rootIndex,
+		EarnerIndex:     uint32(earnerIndex),
+		EarnerTreeProof: flattenHashes(earnerTreeProof),
+		EarnerLeaf: ClaimProofEarnerLeaf{
+			Earner:          earner,
+			EarnerTokenRoot: earnerTokenRoot,
+		},
+		TokenIndices:    tokenIndices,
+		TokenTreeProofs: tokenTreeProofs,
+		TokenLeaves:     tokenLeaves,
+	}, nil
+}
+
+// WriteAllProofs merklizes the distribution and writes a claim for every earner covering all of its
+// tokens to <dir>/<earner>.json, creating dir if needed. The proofs are generated together with
+// Distribution.GenerateAllProofs, and each file is written and synced before the next is encoded.
+//
+// It is a Claimgen method rather than a Distribution one since ClaimProof and its JSON encoding live in
+// this package, which imports distribution, and the claims hold the root index the RewardsCoordinator
+// assigned to the root, which is given like it is to GenerateClaimProof.
+func (c *Claimgen) WriteAllProofs(dir string, rootIndex uint32) error {
+	proofs, err := c.Distribution.GenerateAllProofs()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, earner := range c.Distribution.Earners() {
+		claim := newClaimProofFromEarnerProof(c.Distribution, rootIndex, proofs[earner])
+		if err := writeClaimProof(filepath.Join(dir, earner.Hex()+".json"), claim); err != nil {
+			return fmt.Errorf("failed to write proof for earner %s: %w", earner.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// WriteProofsByToken merklizes the distribution and writes a claim for every earner's token to
+// <dir>/<token>/<earner>.json, creating the directories if needed, so claims can be served by token.
+// Each claim covers that single token, and the proofs are generated together like WriteAllProofs so
+// the earner proof of an earner is shared by all of its token files rather than regenerated.
+func (c *Claimgen) WriteProofsByToken(dir string, rootIndex uint32) error {
+	proofs, err := c.Distribution.GenerateAllProofs()
+	if err != nil {
+		return err
+	}
+	for _, token := range c.Distribution.AllTokens() {
+		if err := os.MkdirAll(filepath.Join(dir, token.Hex()), 0o755); err != nil {
+			return err
+		}
+	}
+
+	for _, earner := range c.Distribution.Earners() {
+		proof := proofs[earner]
+		for i, tokenProof := range proof.TokenProofs {
+			tokenClaim := *proof
+			tokenClaim.TokenProofs = proof.TokenProofs[i : i+1]
+			claim := newClaimProofFromEarnerProof(c.Distribution, rootIndex, &tokenClaim)
+
+			path := filepath.Join(dir, tokenProof.Token.Hex(), earner.Hex()+".json")
+			if err := writeClaimProof(path, claim); err != nil {
+				return fmt.Errorf("failed to write proof for earner %s and token %s: %w", earner.Hex(), tokenProof.Token.Hex(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func newClaimProofFromEarnerProof(d *distribution.Distribution, rootIndex uint32, proof *distribution.EarnerProof) *ClaimProof {
+	tokenIndices := make([]uint32, 0, len(proof.TokenProofs))
+	tokenTreeProofs := make([][]byte, 0, len(proof.TokenProofs))
+	tokenLeaves := make([]ClaimProofTokenLeaf, 0, len(proof.TokenProofs))
+	for _, tokenProof := range proof.TokenProofs {
+		tokenIndices = append(tokenIndices, uint32(tokenProof.TokenIndex))
+		tokenTreeProofs = append(tokenTreeProofs, flattenHashes(tokenProof.TokenTreeProof))
+		tokenLeaves = append(tokenLeaves, ClaimProofTokenLeaf{
+			Token:              tokenProof.Token,
+			CumulativeEarnings: tokenProof.Amount,
+		})
+	}
+
+	var earnerTokenRoot [32]byte
+	copy(earnerTokenRoot[:], proof.EarnerTokenRoot)
+
+	return &ClaimProof{
+		Snapshot:                d.Snapshot,
+		CalculationEndTimestamp: d.CalculationEndTimestamp,
+		RootIndex:               rootIndex,
+		EarnerIndex:             uint32(proof.EarnerIndex),
+		EarnerTreeProof:         flattenHashes(proof.EarnerTreeProof),
+		EarnerLeaf: ClaimProofEarnerLeaf{
+			Earner:          proof.Earner,
+			EarnerTokenRoot: earnerTokenRoot,
+		},
+		TokenIndices:    tokenIndices,
+		TokenTreeProofs: tokenTreeProofs,
+		TokenLeaves:     tokenLeaves,
+	}
+}
+
+func writeClaimProof(path string, claim *ClaimProof) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(claim); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -1,11 +1,15 @@
 package claimgen
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/internal/tests"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -66,3 +70,115 @@ func TestFormatProofForSolidity(t *testing.T) {
 
 	assert.NotNil(t, claimStrings)
 }
+
+func TestWriteAllProofs(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	dir := filepath.Join(t.TempDir(), "proofs")
+
+	distro.Snapshot = 1716681600000
+	cg := NewClaimgen(distro)
+	err := cg.WriteAllProofs(dir, 3)
+	assert.Nil(t, err)
+
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, entries, len(tests.TestAddresses))
+
+	root, err := distro.Root()
+	assert.Nil(t, err)
+
+	earner := tests.TestAddresses[3]
+	data, err := os.ReadFile(filepath.Join(dir, earner.Hex()+".json"))
+	assert.Nil(t, err)
+
+	var claim ClaimProof
+	err = json.Unmarshal(data, &claim)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), claim.RootIndex)
+	assert.Equal(t, uint64(1716681600000), claim.Snapshot)
+	assert.Equal(t, earner, claim.EarnerLeaf.Earner)
+	assert.Len(t, claim.TokenLeaves, len(tests.TestTokens))
+
+	valid, err := VerifyClaim(root, &claim)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+
+	// the claim matches one generated for the same earner and tokens
+	expected, err := cg.GenerateClaimProof(earner, distro.TokensForEarner(earner), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, &claim)
+}
+
+func TestWriteAllProofsEstimateProofBytes(t *testing.T) {
+	withEndTimestamp := getClaimProofTestDistribution(t)
+	withEndTimestamp.CalculationEndTimestamp = 1716768000000
+	for _, distro := range []*distribution.Distribution{getClaimProofTestDistribution(t), getLargeTestDistribution(t, 300), withEndTimestamp} {
+		distro.Snapshot = 1716681600000
+		estimate, err := distro.EstimateProofBytes()
+		assert.Nil(t, err)
+
+		dir := t.TempDir()
+		err = NewClaimgen(distro).WriteAllProofs(dir, 3)
+		assert.Nil(t, err)
+		entries, err := os.ReadDir(dir)
+		assert.Nil(t, err)
+		actual := int64(0)
+		for _, entry := range entries {
+			info, err := entry.Info()
+			assert.Nil(t, err)
+			actual += info.Size()
+		}
+
+		// the estimate is exact for a single digit root index, so a change to the claim format breaks it
+		assert.Equal(t, actual, estimate)
+	}
+}
+
+func TestWriteProofsByToken(t *testing.T) {
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesForSnapshot(getTestEarnerLines(t), 1716681600000)
+	assert.Nil(t, err)
+
+	dir := filepath.Join(t.TempDir(), "proofs")
+	cg := NewClaimgen(distro)
+	err = cg.WriteProofsByToken(dir, 3)
+	assert.Nil(t, err)
+
+	root, err := distro.Root()
+	assert.Nil(t, err)
+
+	// there is a directory per token holding a file per earner of the token
+	entries, err := os.ReadDir(dir)
+	assert.Nil(t, err)
+	tokens := distro.AllTokens()
+	assert.Len(t, entries, len(tokens))
+	files := 0
+	for _, token := range tokens {
+		entries, err := os.ReadDir(filepath.Join(dir, token.Hex()))
+		assert.Nil(t, err)
+		for _, entry := range entries {
+			_, found := distro.Get(common.HexToAddress(strings.TrimSuffix(entry.Name(), ".json")), token)
+			assert.True(t, found, "token %s, file %s", token.Hex(), entry.Name())
+		}
+		files += len(entries)
+	}
+	assert.Equal(t, distro.Len(), files)
+
+	earner := distro.Earners()[len(distro.Earners())/2]
+	for _, token := range distro.TokensForEarner(earner) {
+		data, err := os.ReadFile(filepath.Join(dir, token.Hex(), earner.Hex()+".json"))
+		assert.Nil(t, err)
+		var claim ClaimProof
+		err = json.Unmarshal(data, &claim)
+		assert.Nil(t, err)
+
+		valid, err := VerifyClaim(root, &claim)
+		assert.Nil(t, err)
+		assert.True(t, valid)
+
+		// the claim is the one generated for the token alone
+		expected, err := cg.GenerateClaimProof(earner, []common.Address{token}, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, &claim)
+	}
+}
@@ -0,0 +1,248 @@
+package claimgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	rewardsCoordinator "github.com/Layr-Labs/eigenlayer-contracts/pkg/bindings/IRewardsCoordinator"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// RewardsMerkleClaimABI is the RewardsCoordinator processClaim function, which takes the claim as
+// its first argument. It is used by AbiEncode to lay out the claim tuple.
+const RewardsMerkleClaimABI = `[{"type":"function","name":"processClaim","inputs":[` +
+	`{"name":"claim","type":"tuple","internalType":"struct IRewardsCoordinator.RewardsMerkleClaim","components":[` +
+	`{"name":"rootIndex","type":"uint32"},` +
+	`{"name":"earnerIndex","type":"uint32"},` +
+	`{"name":"earnerTreeProof","type":"bytes"},` +
+	`{"name":"earnerLeaf","type":"tuple","internalType":"struct IRewardsCoordinator.EarnerTreeMerkleLeaf","components":[` +
+	`{"name":"earner","type":"address"},` +
+	`{"name":"earnerTokenRoot","type":"bytes32"}]},` +
+	`{"name":"tokenIndices","type":"uint32[]"},` +
+	`{"name":"tokenTreeProofs","type":"bytes[]"},` +
+	`{"name":"tokenLeaves","type":"tuple[]","internalType":"struct IRewardsCoordinator.TokenTreeMerkleLeaf[]","components":[` +
+	`{"name":"token","type":"address"},` +
+	`{"name":"cumulativeEarnings","type":"uint256"}]}]},` +
+	`{"name":"recipient","type":"address"}],"outputs":[],"stateMutability":"nonpayable"}]`
+
+// ClaimProof is a claim in the JSON format used by the RewardsCoordinator CLI,
+// with bytes encoded as 0x prefixed hex and amounts as decimal strings.
+//
+// Proofs are the concatenated 32 byte sibling hashes, so a tree of n leaves has proofs of
+// 32 * ceil(log2(n)) bytes. A tree with a single leaf has depth zero: its root is the hash
+// of the leaf and its proofs are empty.
+//
+// Snapshot is the snapshot of the distribution the claim was generated from, it is not part of
+// the on-chain claim and is omitted from the JSON when zero. CalculationEndTimestamp is copied from
+// the distribution the same way, and must not be before Snapshot when set.
+type ClaimProof struct {
+	Snapshot                uint64
+	CalculationEndTimestamp uint64
+	RootIndex               uint32
+	EarnerIndex             uint32
+	EarnerTreeProof         []byte
+	EarnerLeaf              ClaimProofEarnerLeaf
+	TokenIndices            []uint32
+	TokenTreeProofs         [][]byte
+	TokenLeaves             []ClaimProofTokenLeaf
+}
+
+type ClaimProofEarnerLeaf struct {
+	Earner          gethcommon.Address
+	EarnerTokenRoot [32]byte
+}
+
+type ClaimProofTokenLeaf struct {
+	Token              gethcommon.Address
+	CumulativeEarnings *big.Int
+}
+
+type claimProofJSON struct {
+	Snapshot                uint64                    `json:"snapshot,omitempty"`
+	CalculationEndTimestamp uint64                    `json:"calculationEndTimestamp,omitempty"`
+	RootIndex               uint32                    `json:"rootIndex"`
+	EarnerIndex             uint32                    `json:"earnerIndex"`
+	EarnerTreeProof         hexutil.Bytes             `json:"earnerTreeProof"`
+	EarnerLeaf              claimProofEarnerLeafJSON  `json:"earnerLeaf"`
+	TokenIndices            []uint32                  `json:"tokenIndices"`
+	TokenTreeProofs         []hexutil.Bytes           `json:"tokenTreeProofs"`
+	TokenLeaves             []claimProofTokenLeafJSON `json:"tokenLeaves"`
+}
+
+type claimProofEarnerLeafJSON struct {
+	Earner          gethcommon.Address `json:"earner"`
+	EarnerTokenRoot gethcommon.Hash    `json:"earnerTokenRoot"`
+}
+
+type claimProofTokenLeafJSON struct {
+	Token              gethcommon.Address `json:"token"`
+	CumulativeEarnings string             `json:"cumulativeEarnings"`
+}
+
+// NewClaimProof converts a claim as used by the RewardsCoordinator bindings
+func NewClaimProof(claim *rewardsCoordinator.IRewardsCoordinatorRewardsMerkleClaim) *ClaimProof {
+	tokenLeaves := make([]ClaimProofTokenLeaf, 0, len(claim.TokenLeaves))
+	for _, leaf := range claim.TokenLeaves {
+		tokenLeaves = append(tokenLeaves, ClaimProofTokenLeaf{
+			Token:              leaf.Token,
+			CumulativeEarnings: leaf.CumulativeEarnings,
+		})
+	}
+	return &ClaimProof{
+		RootIndex:       claim.RootIndex,
+		EarnerIndex:     claim.EarnerIndex,
+		EarnerTreeProof: claim.EarnerTreeProof,
+		EarnerLeaf: ClaimProofEarnerLeaf{
+			Earner:          claim.EarnerLeaf.Earner,
+			EarnerTokenRoot: claim.EarnerLeaf.EarnerTokenRoot,
+		},
+		TokenIndices:    claim.TokenIndices,
+		TokenTreeProofs: claim.TokenTreeProofs,
+		TokenLeaves:     tokenLeaves,
+	}
+}
+
+func (p *ClaimProof) MarshalJSON() ([]byte, error) {
+	tokenTreeProofs := make([]hexutil.Bytes, 0, len(p.TokenTreeProofs))
+	for _, proof := range p.TokenTreeProofs {
+		tokenTreeProofs = append(tokenTreeProofs, proof)
+	}
+	tokenLeaves := make([]claimProofTokenLeafJSON, 0, len(p.TokenLeaves))
+	for _, leaf := range p.TokenLeaves {
+		cumulativeEarnings := "0"
+		if leaf.CumulativeEarnings != nil {
+			cumulativeEarnings = leaf.CumulativeEarnings.String()
+		}
+		tokenLeaves = append(tokenLeaves, claimProofTokenLeafJSON{
+			Token:              leaf.Token,
+			CumulativeEarnings: cumulativeEarnings,
+		})
+	}
+	tokenIndices := p.TokenIndices
+	if tokenIndices == nil {
+		tokenIndices = []uint32{}
+	}
+
+	return json.Marshal(&claimProofJSON{
+		Snapshot:                p.Snapshot,
+		CalculationEndTimestamp: p.CalculationEndTimestamp,
+		RootIndex:               p.RootIndex,
+		EarnerIndex:             p.EarnerIndex,
+		EarnerTreeProof:         p.EarnerTreeProof,
+		EarnerLeaf: claimProofEarnerLeafJSON{
+			Earner:          p.EarnerLeaf.Earner,
+			EarnerTokenRoot: p.EarnerLeaf.EarnerTokenRoot,
+		},
+		TokenIndices:    tokenIndices,
+		TokenTreeProofs: tokenTreeProofs,
+		TokenLeaves:     tokenLeaves,
+	})
+}
+
+func (p *ClaimProof) UnmarshalJSON(data []byte) error {
+	var aux claimProofJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if err := distribution.CheckCalculationEndTimestamp(aux.Snapshot, aux.CalculationEndTimestamp); err != nil {
+		return err
+	}
+
+	tokenTreeProofs := make([][]byte, 0, len(aux.TokenTreeProofs))
+	for _, proof := range aux.TokenTreeProofs {
+		tokenTreeProofs = append(tokenTreeProofs, proof)
+	}
+	tokenLeaves := make([]ClaimProofTokenLeaf, 0, len(aux.TokenLeaves))
+	for _, leaf := range aux.TokenLeaves {
+		cumulativeEarnings, ok := new(big.Int).SetString(leaf.CumulativeEarnings, 10)
+		if !ok {
+			return fmt.Errorf("invalid cumulative earnings for token %s: %s", leaf.Token.Hex(), leaf.CumulativeEarnings)
+		}
+		tokenLeaves = append(tokenLeaves, ClaimProofTokenLeaf{
+			Token:              leaf.Token,
+			CumulativeEarnings: cumulativeEarnings,
+		})
+	}
+
+	*p = ClaimProof{
+		Snapshot:                aux.Snapshot,
+		CalculationEndTimestamp: aux.CalculationEndTimestamp,
+		RootIndex:               aux.RootIndex,
+		EarnerIndex:             aux.EarnerIndex,
+		EarnerTreeProof:         aux.EarnerTreeProof,
+		EarnerLeaf: ClaimProofEarnerLeaf{
+			Earner:          aux.EarnerLeaf.Earner,
+			EarnerTokenRoot: aux.EarnerLeaf.EarnerTokenRoot,
+		},
+		TokenIndices:    aux.TokenIndices,
+		TokenTreeProofs: tokenTreeProofs,
+		TokenLeaves:     tokenLeaves,
+	}
+	return nil
+}
+
+// claimArguments returns the abi arguments of a single RewardsMerkleClaim tuple
+func claimArguments() (abi.Arguments, error) {
+	parsed, err := abi.JSON(strings.NewReader(RewardsMerkleClaimABI))
+	if err != nil {
+		return nil, err
+	}
+	return abi.Arguments{parsed.Methods["processClaim"].Inputs[0]}, nil
+}
+
+// AbiEncode encodes the claim as the RewardsMerkleClaim tuple passed to RewardsCoordinator.processClaim,
+// without the method selector or the recipient. The snapshot is not part of the encoding.
+func (p *ClaimProof) AbiEncode() ([]byte, error) {
+	args, err := claimArguments()
+	if err != nil {
+		return nil, err
+	}
+	return args.Pack(p.toMerkleClaim())
+}
+
+// toMerkleClaim converts the claim to the RewardsCoordinator bindings, with nil amounts as zero
+func (p *ClaimProof) toMerkleClaim() rewardsCoordinator.IRewardsCoordinatorRewardsMerkleClaim {
+	tokenIndices := p.TokenIndices
+	if tokenIndices == nil {
+		tokenIndices = []uint32{}
+	}
+	tokenTreeProofs := p.TokenTreeProofs
+	if tokenTreeProofs == nil {
+		tokenTreeProofs = [][]byte{}
+	}
+	tokenLeaves := make([]rewardsCoordinator.IRewardsCoordinatorTokenTreeMerkleLeaf, 0, len(p.TokenLeaves))
+	for _, leaf := range p.TokenLeaves {
+		cumulativeEarnings := leaf.CumulativeEarnings
+		if cumulativeEarnings == nil {
+			cumulativeEarnings = big.NewInt(0)
+		}
+		tokenLeaves = append(tokenLeaves, rewardsCoordinator.IRewardsCoordinatorTokenTreeMerkleLeaf{
+			Token:              leaf.Token,
+			CumulativeEarnings: cumulativeEarnings,
+		})
+	}
+	earnerTreeProof := p.EarnerTreeProof
+	if earnerTreeProof == nil {
+		earnerTreeProof = []byte{}
+	}
+
+	return rewardsCoordinator.IRewardsCoordinatorRewardsMerkleClaim{
+		RootIndex:       p.RootIndex,
+		EarnerIndex:     p.EarnerIndex,
+		EarnerTreeProof: earnerTreeProof,
+		EarnerLeaf: rewardsCoordinator.IRewardsCoordinatorEarnerTreeMerkleLeaf{
+			Earner:          p.EarnerLeaf.Earner,
+			EarnerTokenRoot: p.EarnerLeaf.EarnerTokenRoot,
+		},
+		TokenIndices:    tokenIndices,
+		TokenTreeProofs: tokenTreeProofs,
+		TokenLeaves:     tokenLeaves,
+	}
+}
@@ -0,0 +1,240 @@
+package claimgen
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	rewardsCoordinator "github.com/Layr-Labs/eigenlayer-contracts/pkg/bindings/IRewardsCoordinator"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+// getClaimProofTestDistribution gives every test address every test token, with amounts
+// taken from tests.TestAmountsString and rotated by the address index
+func getClaimProofTestDistribution(t *testing.T) *distribution.Distribution {
+	distro := distribution.NewDistribution()
+	for i, earner := range tests.TestAddresses {
+		for j, token := range tests.TestTokens {
+			amount, ok := new(big.Int).SetString(tests.TestAmountsString[(i+j)%len(tests.TestAmountsString)], 10)
+			assert.True(t, ok)
+			err := distro.Set(earner, token, amount)
+			assert.Nil(t, err)
+		}
+	}
+	return distro
+}
+
+// getTestEarnerLines parses the lines of the full fixture, which spans several snapshots
+func getTestEarnerLines(t *testing.T) []*distribution.EarnerLine {
+	lines := make([]*distribution.EarnerLine, 0)
+	for _, raw := range strings.Split(tests.GetFullTestEarnerLines(), "\n") {
+		if raw == "" {
+			continue
+		}
+		line := &distribution.EarnerLine{}
+		assert.Nil(t, json.Unmarshal([]byte(raw), line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestClaimProofMarshalJSON(t *testing.T) {
+	cg := NewClaimgen(getClaimProofTestDistribution(t))
+
+	proof, err := cg.GenerateClaimProof(tests.TestAddresses[2], []common.Address{tests.TestTokens[1], tests.TestTokens[3]}, 7)
+	assert.Nil(t, err)
+
+	actual, err := json.MarshalIndent(proof, "", "  ")
+	assert.Nil(t, err)
+
+	expected, err := os.ReadFile("testdata/claim_proof.json")
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestGenerateClaimProofMatchesGetProofForEarner(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	cg := NewClaimgen(distro)
+	tokens := []common.Address{tests.TestTokens[0], tests.TestTokens[4]}
+
+	for _, earner := range tests.TestAddresses {
+		proof, err := cg.GenerateClaimProof(earner, tokens, 1)
+		assert.Nil(t, err)
+		_, claim, err := cg.GenerateClaimProofForEarner(earner, tokens, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, NewClaimProof(claim), proof)
+	}
+
+	_, err := cg.GenerateClaimProof(common.HexToAddress("0x01"), tokens, 1)
+	assert.ErrorIs(t, err, ErrEarnerIndexNotFound)
+	_, err = cg.GenerateClaimProof(tests.TestAddresses[0], []common.Address{common.HexToAddress("0x01")}, 1)
+	assert.ErrorIs(t, err, ErrTokenIndexNotFound)
+}
+
+func TestGenerateFrozenClaimProof(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	distro.Snapshot = 1716681600000
+	cg := NewClaimgen(distro)
+	frozen, err := distro.Freeze()
+	assert.Nil(t, err)
+	tokens := []common.Address{tests.TestTokens[1], tests.TestTokens[3]}
+
+	for _, earner := range tests.TestAddresses {
+		proof, err := GenerateFrozenClaimProof(frozen, earner, tokens, 2)
+		assert.Nil(t, err)
+		expected, err := cg.GenerateClaimProof(earner, tokens, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, proof)
+	}
+
+	_, err = GenerateFrozenClaimProof(frozen, common.HexToAddress("0x01"), tokens, 2)
+	assert.ErrorIs(t, err, ErrEarnerIndexNotFound)
+	_, err = GenerateFrozenClaimProof(frozen, tests.TestAddresses[0], []common.Address{common.HexToAddress("0x01")}, 2)
+	assert.ErrorIs(t, err, ErrTokenIndexNotFound)
+}
+
+func TestGenerateClaimProofMultipleTokens(t *testing.T) {
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesForSnapshot(getTestEarnerLines(t), 1716681600000)
+	assert.Nil(t, err)
+	root, err := distro.Root()
+	assert.Nil(t, err)
+
+	// this earner holds 9 tokens in the fixture
+	earner := common.HexToAddress("0x055fc8880e53d9d063f78d4fc0b8750bda2e73c6")
+	tokens := distro.TokensForEarner(earner)
+	assert.Len(t, tokens, 9)
+
+	claim, err := NewClaimgen(distro).GenerateClaimProof(earner, tokens, 0)
+	assert.Nil(t, err)
+	assert.Len(t, claim.TokenIndices, len(tokens))
+	assert.Len(t, claim.TokenTreeProofs, len(tokens))
+	assert.Len(t, claim.TokenLeaves, len(tokens))
+	for i, token := range tokens {
+		amount, _ := distro.Get(earner, token)
+		assert.Equal(t, uint32(i), claim.TokenIndices[i])
+		assert.Equal(t, token, claim.TokenLeaves[i].Token)
+		assert.Equal(t, amount, claim.TokenLeaves[i].CumulativeEarnings)
+	}
+
+	valid, err := VerifyClaim(root, claim)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+
+	// every token the earner does not hold is named
+	unknown := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	_, err = NewClaimgen(distro).GenerateClaimProof(earner, []common.Address{tokens[0], unknown[0], unknown[1]}, 0)
+	assert.ErrorIs(t, err, ErrTokenIndexNotFound)
+	assert.ErrorContains(t, err, unknown[0].Hex()+", "+unknown[1].Hex())
+}
+
+func TestClaimProofUnmarshalJSON(t *testing.T) {
+	expected, err := os.ReadFile("testdata/claim_proof.json")
+	assert.Nil(t, err)
+
+	var proof ClaimProof
+	err = json.Unmarshal(expected, &proof)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(7), proof.RootIndex)
+	assert.Equal(t, tests.TestAddresses[2], proof.EarnerLeaf.Earner)
+	assert.Len(t, proof.TokenTreeProofs, 2)
+	assert.Len(t, proof.TokenLeaves, 2)
+
+	actual, err := json.Marshal(&proof)
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestClaimProofSnapshot(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	distro.Snapshot = 1716681600000
+	cg := NewClaimgen(distro)
+
+	proof, err := cg.GenerateClaimProof(tests.TestAddresses[2], []common.Address{tests.TestTokens[1], tests.TestTokens[3]}, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1716681600000), proof.Snapshot)
+
+	data, err := json.Marshal(proof)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"snapshot":1716681600000`)
+
+	var decoded ClaimProof
+	err = json.Unmarshal(data, &decoded)
+	assert.Nil(t, err)
+	assert.Equal(t, proof, &decoded)
+
+	// the snapshot is omitted when the distribution does not have one
+	proof.Snapshot = 0
+	data, err = json.Marshal(proof)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "snapshot")
+}
+
+func TestClaimProofCalculationEndTimestamp(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	distro.Snapshot = 1716681600000
+	err := distro.SetCalculationEndTimestamp(1716768000000)
+	assert.Nil(t, err)
+	cg := NewClaimgen(distro)
+
+	proof, err := cg.GenerateClaimProof(tests.TestAddresses[2], []common.Address{tests.TestTokens[1]}, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1716768000000), proof.CalculationEndTimestamp)
+
+	data, err := json.Marshal(proof)
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"calculationEndTimestamp":1716768000000`)
+
+	var decoded ClaimProof
+	err = json.Unmarshal(data, &decoded)
+	assert.Nil(t, err)
+	assert.Equal(t, proof, &decoded)
+
+	// a claim ending before its snapshot is rejected
+	proof.CalculationEndTimestamp = 1716681599999
+	data, err = json.Marshal(proof)
+	assert.Nil(t, err)
+	err = json.Unmarshal(data, &decoded)
+	assert.ErrorIs(t, err, distribution.ErrEndBeforeSnapshot)
+
+	proof.CalculationEndTimestamp = 0
+	data, err = json.Marshal(proof)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(data), "calculationEndTimestamp")
+}
+
+func TestClaimProofAbiEncode(t *testing.T) {
+	cg := NewClaimgen(getClaimProofTestDistribution(t))
+
+	proof, err := cg.GenerateClaimProof(tests.TestAddresses[2], []common.Address{tests.TestTokens[1], tests.TestTokens[3]}, 7)
+	assert.Nil(t, err)
+
+	encoded, err := proof.AbiEncode()
+	assert.Nil(t, err)
+
+	args, err := claimArguments()
+	assert.Nil(t, err)
+	values, err := args.Unpack(encoded)
+	assert.Nil(t, err)
+	assert.Len(t, values, 1)
+
+	decoded := *abi.ConvertType(values[0], new(rewardsCoordinator.IRewardsCoordinatorRewardsMerkleClaim)).(*rewardsCoordinator.IRewardsCoordinatorRewardsMerkleClaim)
+	assert.Equal(t, proof, NewClaimProof(&decoded))
+
+	// the encoding matches the bindings of the contract
+	parsed, err := rewardsCoordinator.IRewardsCoordinatorMetaData.GetAbi()
+	assert.Nil(t, err)
+	calldata, err := parsed.Pack("processClaim", decoded, tests.TestAddresses[0])
+	assert.Nil(t, err)
+	recipient := common.LeftPadBytes(tests.TestAddresses[0].Bytes(), 32)
+	// the claim is dynamic so calldata is selector || offset || recipient || claim
+	assert.Equal(t, recipient, calldata[4+32:4+64])
+	assert.Equal(t, encoded[32:], calldata[4+64:])
+}
@@ -0,0 +1,150 @@
+package claimgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+var ErrMalformedCompactProofSet = errors.New("malformed compact proof set")
+
+// CompactProofSet holds the claims of every earner of a distribution with the account tree stored once,
+// instead of repeating the account tree siblings in every claim. Expand rebuilds a single earner's
+// ClaimProof on demand.
+//
+// A claim file carries ceil(log2(n)) account tree siblings for each of n earners, so the per-file
+// proofs of a distribution hold 32 * n * ceil(log2(n)) bytes of account tree hashes. The compact set
+// holds each node once, fewer than 2 * 2^ceil(log2(n)) hashes, with the zero padding leaves left out:
+// for one million earners this is about 66MB instead of 640MB. The token tree proofs are different
+// for every earner and are kept as they are.
+type CompactProofSet struct {
+	// AccountTreeNodes are the nodes of the account tree in breadth first order starting at the root,
+	// so the children of node i are 2i+1 and 2i+2. The trailing padding leaves are omitted.
+	AccountTreeNodes [][]byte
+
+	// Claims are the claims of every earner in account tree order, without their EarnerTreeProof.
+	Claims []ClaimProof
+}
+
+type compactProofSetJSON struct {
+	AccountTreeNodes []hexutil.Bytes `json:"accountTreeNodes"`
+	Claims           []*ClaimProof   `json:"claims"`
+}
+
+// GenerateCompactProofSet merklizes the distribution and returns the claims of every earner covering
+// all of its tokens, in the same form as WriteAllProofs but with the account tree stored once.
+func (c *Claimgen) GenerateCompactProofSet(rootIndex uint32) (*CompactProofSet, error) {
+	accountTree, _, err := c.Distribution.Merklize()
+	if err != nil {
+		return nil, err
+	}
+	proofs, err := c.Distribution.GenerateAllProofs()
+	if err != nil {
+		return nil, err
+	}
+
+	earners := c.Distribution.Earners()
+	claims := make([]ClaimProof, 0, len(earners))
+	for _, earner := range earners {
+		claim := newClaimProofFromEarnerProof(c.Distribution, rootIndex, proofs[earner])
+		claim.EarnerTreeProof = nil
+		claims = append(claims, *claim)
+	}
+
+	// the leaves start half way through the nodes, the first node is unused
+	leavesOffset := len(accountTree.Nodes) / 2
+	return &CompactProofSet{
+		AccountTreeNodes: accountTree.Nodes[1 : leavesOffset+len(earners)],
+		Claims:           claims,
+	}, nil
+}
+
+// Root returns the root of the account tree, nil if the set is empty.
+func (s *CompactProofSet) Root() []byte {
+	if len(s.AccountTreeNodes) == 0 {
+		return nil
+	}
+	return s.AccountTreeNodes[0]
+}
+
+// Expand returns the claim of an earner with its account tree proof rebuilt from AccountTreeNodes,
+// returning ErrEarnerIndexNotFound if the earner is not in the set.
+func (s *CompactProofSet) Expand(earner gethcommon.Address) (*ClaimProof, error) {
+	index := sort.Search(len(s.Claims), func(i int) bool {
+		return distribution.CompareAddresses(s.Claims[i].EarnerLeaf.Earner, earner) >= 0
+	})
+	if index == len(s.Claims) || s.Claims[index].EarnerLeaf.Earner != earner {
+		return nil, fmt.Errorf("%w for earner %s", ErrEarnerIndexNotFound, earner.Hex())
+	}
+
+	leavesOffset := 1
+	for leavesOffset < len(s.Claims) {
+		leavesOffset *= 2
+	}
+	if len(s.AccountTreeNodes) != leavesOffset+len(s.Claims)-1 {
+		return nil, fmt.Errorf("%w - claims: %d, account tree nodes: %d, expected: %d", ErrMalformedCompactProofSet,
+			len(s.Claims), len(s.AccountTreeNodes), leavesOffset+len(s.Claims)-1)
+	}
+
+	claim := s.Claims[index]
+	earnerTreeProof := make([]byte, 0)
+	for i := index + leavesOffset; i > 1; i /= 2 {
+		earnerTreeProof = append(earnerTreeProof, s.node(i^1)...)
+	}
+	claim.EarnerTreeProof = earnerTreeProof
+	return &claim, nil
+}
+
+// node returns a node by its index in the tree, where the root is 1, and zero for the omitted padding
+func (s *CompactProofSet) node(i int) []byte {
+	if i > len(s.AccountTreeNodes) {
+		return make([]byte, 32)
+	}
+	return s.AccountTreeNodes[i-1]
+}
+
+func (s *CompactProofSet) MarshalJSON() ([]byte, error) {
+	nodes := make([]hexutil.Bytes, 0, len(s.AccountTreeNodes))
+	for _, node := range s.AccountTreeNodes {
+		nodes = append(nodes, node)
+	}
+	claims := make([]*ClaimProof, 0, len(s.Claims))
+	for i := range s.Claims {
+		claims = append(claims, &s.Claims[i])
+	}
+	return json.Marshal(&compactProofSetJSON{
+		AccountTreeNodes: nodes,
+		Claims:           claims,
+	})
+}
+
+func (s *CompactProofSet) UnmarshalJSON(data []byte) error {
+	var aux compactProofSetJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	nodes := make([][]byte, 0, len(aux.AccountTreeNodes))
+	for _, node := range aux.AccountTreeNodes {
+		nodes = append(nodes, node)
+	}
+	claims := make([]ClaimProof, 0, len(aux.Claims))
+	for _, claim := range aux.Claims {
+		if claim == nil {
+			return fmt.Errorf("%w - claim: %d, null claim", ErrMalformedCompactProofSet, len(claims))
+		}
+		claims = append(claims, *claim)
+	}
+
+	*s = CompactProofSet{
+		AccountTreeNodes: nodes,
+		Claims:           claims,
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package claimgen
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompactProofSet(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	distro.Snapshot = 1716681600000
+	root, claims := getTestClaims(t, distro)
+	for i := range claims {
+		claims[i].RootIndex = 3
+	}
+
+	set, err := NewClaimgen(distro).GenerateCompactProofSet(3)
+	assert.Nil(t, err)
+	assert.Equal(t, root, set.Root())
+	assert.Len(t, set.Claims, len(claims))
+
+	data, err := json.Marshal(set)
+	assert.Nil(t, err)
+	var decoded CompactProofSet
+	err = json.Unmarshal(data, &decoded)
+	assert.Nil(t, err)
+
+	for i, earner := range distro.Earners() {
+		claim, err := decoded.Expand(earner)
+		assert.Nil(t, err)
+		assert.Equal(t, &claims[i], claim)
+
+		valid, err := VerifyClaim(root, claim)
+		assert.Nil(t, err)
+		assert.True(t, valid)
+	}
+
+	_, err = decoded.Expand(common.HexToAddress("0x01"))
+	assert.ErrorIs(t, err, ErrEarnerIndexNotFound)
+
+	decoded.AccountTreeNodes = decoded.AccountTreeNodes[1:]
+	_, err = decoded.Expand(tests.TestAddresses[0])
+	assert.ErrorIs(t, err, ErrMalformedCompactProofSet)
+}
+
+func TestCompactProofSetPadding(t *testing.T) {
+	// 5 earners are padded to 8 leaves, and a single earner has an empty proof
+	for _, n := range []int{1, 2, 5} {
+		distro := getLargeTestDistribution(t, n)
+		root, claims := getTestClaims(t, distro)
+
+		set, err := NewClaimgen(distro).GenerateCompactProofSet(0)
+		assert.Nil(t, err)
+		for i, earner := range distro.Earners() {
+			claim, err := set.Expand(earner)
+			assert.Nil(t, err)
+			assert.Equal(t, claims[i].EarnerTreeProof, claim.EarnerTreeProof)
+
+			valid, err := VerifyClaim(root, claim)
+			assert.Nil(t, err)
+			assert.True(t, valid)
+		}
+	}
+}
+
+func TestCompactProofSetSize(t *testing.T) {
+	distro := getLargeTestDistribution(t, 1000)
+	_, claims := getTestClaims(t, distro)
+
+	perFile := 0
+	for i := range claims {
+		data, err := json.Marshal(&claims[i])
+		assert.Nil(t, err)
+		perFile += len(data)
+	}
+
+	set, err := NewClaimgen(distro).GenerateCompactProofSet(0)
+	assert.Nil(t, err)
+	data, err := json.Marshal(set)
+	assert.Nil(t, err)
+	assert.Less(t, len(data), perFile)
+}
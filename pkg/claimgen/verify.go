@@ -0,0 +1,150 @@
+package claimgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+)
+
+var ErrInvalidRoot = errors.New("root must be 32 bytes")
+var ErrMalformedClaim = errors.New("malformed claim")
+var ErrEarnerTokenRootMismatch = errors.New("token leaves do not reconstruct the earner token root")
+
+// VerifyClaim checks a claim against a root the same way the RewardsCoordinator does,
+// hashing with keccak256. Malformed claims are reported as invalid rather than as an error,
+// use VerifyEarnerTokenRoot to find out which token leaf disagrees with the earner leaf.
+//
+// Each token leaf is checked against its own index and proof, so the tokens may be in any order as long
+// as TokenIndices, TokenTreeProofs and TokenLeaves stay aligned with each other.
+func VerifyClaim(root []byte, claim *ClaimProof) (bool, error) {
+	if len(root) != 32 {
+		return false, fmt.Errorf("%w, got %d", ErrInvalidRoot, len(root))
+	}
+	return newClaimVerifier(false).verify(root, claim), nil
+}
+
+// VerifyClaimBatch checks many claims against one root, the results are aligned with the claims.
+// Hashes of internal nodes are memoized, so claims sharing a path through the account tree,
+// or tokens sharing a path through a token tree, only hash the shared nodes once.
+func VerifyClaimBatch(root []byte, claims []ClaimProof) ([]bool, error) {
+	if len(root) != 32 {
+		return nil, fmt.Errorf("%w, got %d", ErrInvalidRoot, len(root))
+	}
+	verifier := newClaimVerifier(true)
+	results := make([]bool, len(claims))
+	for i := range claims {
+		results[i] = verifier.verify(root, &claims[i])
+	}
+	return results, nil
+}
+
+// VerifyEarnerTokenRoot reconstructs the earner token root from every token leaf and proof of the claim
+// and checks they all agree with the root embedded in the earner leaf, catching a forged earnerTokenRoot
+// independently of the account tree. ErrEarnerTokenRootMismatch is returned for the first token that
+// disagrees, and ErrMalformedClaim if the claim has no tokens or cannot be reconstructed.
+func VerifyEarnerTokenRoot(claim *ClaimProof) error {
+	if len(claim.TokenLeaves) == 0 {
+		return fmt.Errorf("%w - earner: %s, no token leaves", ErrMalformedClaim, claim.EarnerLeaf.Earner.Hex())
+	}
+	if len(claim.TokenIndices) != len(claim.TokenTreeProofs) || len(claim.TokenIndices) != len(claim.TokenLeaves) {
+		return fmt.Errorf("%w - earner: %s, token indices: %d, proofs: %d, leaves: %d", ErrMalformedClaim,
+			claim.EarnerLeaf.Earner.Hex(), len(claim.TokenIndices), len(claim.TokenTreeProofs), len(claim.TokenLeaves))
+	}
+
+	v := newClaimVerifier(false)
+	for i, leaf := range claim.TokenLeaves {
+		if leaf.CumulativeEarnings == nil || leaf.CumulativeEarnings.Sign() < 0 || leaf.CumulativeEarnings.BitLen() > 256 {
+			return fmt.Errorf("%w - earner: %s, token: %s, invalid amount", ErrMalformedClaim, claim.EarnerLeaf.Earner.Hex(), leaf.Token.Hex())
+		}
+		leafHash := distribution.HashLeaf(distribution.EncodeTokenLeaf(leaf.Token, leaf.CumulativeEarnings))
+		root, ok := v.computeRoot(leafHash, uint64(claim.TokenIndices[i]), claim.TokenTreeProofs[i])
+		if !ok {
+			return fmt.Errorf("%w - earner: %s, token: %s, invalid proof", ErrMalformedClaim, claim.EarnerLeaf.Earner.Hex(), leaf.Token.Hex())
+		}
+		if !bytes.Equal(root, claim.EarnerLeaf.EarnerTokenRoot[:]) {
+			return fmt.Errorf("%w - earner: %s, token: %s, reconstructed: %x, embedded: %x", ErrEarnerTokenRootMismatch,
+				claim.EarnerLeaf.Earner.Hex(), leaf.Token.Hex(), root, claim.EarnerLeaf.EarnerTokenRoot)
+		}
+	}
+	return nil
+}
+
+type claimVerifier struct {
+	// parent hashes keyed by the concatenation of their left and right children, nil when not memoizing
+	parents map[[64]byte][]byte
+}
+
+func newClaimVerifier(memoize bool) *claimVerifier {
+	v := &claimVerifier{}
+	if memoize {
+		v.parents = make(map[[64]byte][]byte)
+	}
+	return v
+}
+
+func (v *claimVerifier) verify(root []byte, claim *ClaimProof) bool {
+	if len(claim.TokenIndices) != len(claim.TokenTreeProofs) || len(claim.TokenIndices) != len(claim.TokenLeaves) {
+		return false
+	}
+
+	earnerTokenRoot := claim.EarnerLeaf.EarnerTokenRoot[:]
+	for i, leaf := range claim.TokenLeaves {
+		if leaf.CumulativeEarnings == nil || leaf.CumulativeEarnings.Sign() < 0 || leaf.CumulativeEarnings.BitLen() > 256 {
+			return false
+		}
+		leafHash := distribution.HashLeaf(distribution.EncodeTokenLeaf(leaf.Token, leaf.CumulativeEarnings))
+		if !v.verifyInclusion(earnerTokenRoot, leafHash, uint64(claim.TokenIndices[i]), claim.TokenTreeProofs[i]) {
+			return false
+		}
+	}
+
+	leafHash := distribution.HashLeaf(distribution.EncodeAccountLeaf(claim.EarnerLeaf.Earner, earnerTokenRoot))
+	return v.verifyInclusion(root, leafHash, uint64(claim.EarnerIndex), claim.EarnerTreeProof)
+}
+
+// verifyInclusion walks a flattened proof of 32 byte siblings from the leaf hash up to the root
+func (v *claimVerifier) verifyInclusion(root, leafHash []byte, index uint64, proof []byte) bool {
+	computed, ok := v.computeRoot(leafHash, index, proof)
+	return ok && bytes.Equal(computed, root)
+}
+
+// computeRoot returns the root reached from the leaf hash, or false if the proof or index is malformed
+func (v *claimVerifier) computeRoot(leafHash []byte, index uint64, proof []byte) ([]byte, bool) {
+	if len(proof)%32 != 0 {
+		return nil, false
+	}
+	depth := len(proof) / 32
+	if depth < 64 && index >= 1<<depth {
+		return nil, false
+	}
+
+	node := leafHash
+	for i := 0; i < depth; i++ {
+		sibling := proof[i*32 : (i+1)*32]
+		if index%2 == 0 {
+			node = v.hashPair(node, sibling)
+		} else {
+			node = v.hashPair(sibling, node)
+		}
+		index /= 2
+	}
+	return node, true
+}
+
+func (v *claimVerifier) hashPair(left, right []byte) []byte {
+	if v.parents == nil {
+		return distribution.HashNodes(left, right)
+	}
+
+	var key [64]byte
+	copy(key[:32], left)
+	copy(key[32:], right)
+	if parent, found := v.parents[key]; found {
+		return parent
+	}
+	parent := distribution.HashNodes(left, right)
+	v.parents[key] = parent
+	return parent
+}
@@ -0,0 +1,239 @@
+package claimgen
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+// getTestClaims generates a claim for every earner of the distribution covering all of its tokens
+func getTestClaims(t testing.TB, distro *distribution.Distribution) ([]byte, []ClaimProof) {
+	root, err := distro.Root()
+	assert.Nil(t, err)
+
+	cg := NewClaimgen(distro)
+	claims := make([]ClaimProof, 0)
+	for _, earner := range distro.Earners() {
+		claim, err := cg.GenerateClaimProof(earner, distro.TokensForEarner(earner), 0)
+		assert.Nil(t, err)
+		claims = append(claims, *claim)
+	}
+	return root, claims
+}
+
+// getLargeTestDistribution gives n earners two tokens each
+func getLargeTestDistribution(t testing.TB, n int) *distribution.Distribution {
+	distro := distribution.NewDistribution()
+	for i := 0; i < n; i++ {
+		earner := common.BigToAddress(big.NewInt(int64(i + 1)))
+		for j, token := range tests.TestTokens[:2] {
+			err := distro.Set(earner, token, big.NewInt(int64(i*2+j+1)))
+			assert.Nil(t, err)
+		}
+	}
+	return distro
+}
+
+func TestVerifyClaim(t *testing.T) {
+	root, claims := getTestClaims(t, getClaimProofTestDistribution(t))
+	for i := range claims {
+		valid, err := VerifyClaim(root, &claims[i])
+		assert.Nil(t, err)
+		assert.True(t, valid)
+	}
+
+	_, err := VerifyClaim(root[:31], &claims[0])
+	assert.ErrorIs(t, err, ErrInvalidRoot)
+}
+
+func TestVerifyClaimTokensOutOfOrder(t *testing.T) {
+	distro := getClaimProofTestDistribution(t)
+	root, err := distro.Root()
+	assert.Nil(t, err)
+
+	tokens := []common.Address{tests.TestTokens[3], tests.TestTokens[1]}
+	claim, err := NewClaimgen(distro).GenerateClaimProof(tests.TestAddresses[2], tokens, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{3, 1}, claim.TokenIndices)
+
+	valid, err := VerifyClaim(root, claim)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+	assert.Nil(t, VerifyEarnerTokenRoot(claim))
+
+	// swapping only the leaves misaligns them with their proofs
+	claim.TokenLeaves[0], claim.TokenLeaves[1] = claim.TokenLeaves[1], claim.TokenLeaves[0]
+	valid, err = VerifyClaim(root, claim)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+}
+
+func TestVerifyClaimBatch(t *testing.T) {
+	root, claims := getTestClaims(t, getClaimProofTestDistribution(t))
+
+	// tamper with some of the claims
+	claims[1].TokenLeaves[0].CumulativeEarnings = new(big.Int).Add(claims[1].TokenLeaves[0].CumulativeEarnings, big.NewInt(1))
+	claims[3].EarnerIndex = 2
+	claims[4].EarnerTreeProof = claims[4].EarnerTreeProof[:len(claims[4].EarnerTreeProof)-1]
+
+	results, err := VerifyClaimBatch(root, claims)
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false, true, false, false}, results)
+
+	for i := range claims {
+		valid, err := VerifyClaim(root, &claims[i])
+		assert.Nil(t, err)
+		assert.Equal(t, results[i], valid)
+	}
+
+	results, err = VerifyClaimBatch(root, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+
+	_, err = VerifyClaimBatch(nil, claims)
+	assert.ErrorIs(t, err, ErrInvalidRoot)
+}
+
+func TestVerifyClaimBatchLarge(t *testing.T) {
+	root, claims := getTestClaims(t, getLargeTestDistribution(t, 100))
+
+	results, err := VerifyClaimBatch(root, claims)
+	assert.Nil(t, err)
+	for _, valid := range results {
+		assert.True(t, valid)
+	}
+
+	otherRoot, _ := getTestClaims(t, getClaimProofTestDistribution(t))
+	results, err = VerifyClaimBatch(otherRoot, claims)
+	assert.Nil(t, err)
+	for _, valid := range results {
+		assert.False(t, valid)
+	}
+}
+
+func BenchmarkVerifyClaimBatch(b *testing.B) {
+	root, claims := getTestClaims(b, getLargeTestDistribution(b, 4096))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = VerifyClaimBatch(root, claims)
+	}
+}
+
+func BenchmarkVerifyClaimIndependently(b *testing.B) {
+	root, claims := getTestClaims(b, getLargeTestDistribution(b, 4096))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range claims {
+			_, _ = VerifyClaim(root, &claims[j])
+		}
+	}
+}
+
+func TestVerifyClaimSingleEarnerSingleToken(t *testing.T) {
+	distro := distribution.NewDistribution()
+	err := distro.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(42))
+	assert.Nil(t, err)
+
+	root, claims := getTestClaims(t, distro)
+	claim := claims[0]
+
+	// both trees have depth zero, so the proofs are empty and each root is the hash of its leaf
+	assert.Empty(t, claim.EarnerTreeProof)
+	assert.Len(t, claim.TokenTreeProofs, 1)
+	assert.Empty(t, claim.TokenTreeProofs[0])
+	tokenRoot := crypto.Keccak256(distribution.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(42)))
+	assert.Equal(t, tokenRoot, claim.EarnerLeaf.EarnerTokenRoot[:])
+	assert.Equal(t, crypto.Keccak256(distribution.EncodeAccountLeaf(tests.TestAddresses[0], tokenRoot)), root)
+
+	valid, err := VerifyClaim(root, &claim)
+	assert.Nil(t, err)
+	assert.True(t, valid)
+
+	// an empty proof only proves index zero
+	claim.EarnerIndex = 1
+	valid, err = VerifyClaim(root, &claim)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	claim.EarnerIndex = 0
+	claim.TokenLeaves[0].CumulativeEarnings = big.NewInt(43)
+	valid, err = VerifyClaim(root, &claim)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+}
+
+func TestVerifyClaimSingleEarner(t *testing.T) {
+	distro := distribution.NewDistribution()
+	for j, token := range tests.TestTokens[:3] {
+		err := distro.Set(tests.TestAddresses[0], token, big.NewInt(int64(j+1)))
+		assert.Nil(t, err)
+	}
+
+	root, claims := getTestClaims(t, distro)
+	assert.Empty(t, claims[0].EarnerTreeProof)
+	for _, proof := range claims[0].TokenTreeProofs {
+		// 3 tokens are padded to 4 leaves
+		assert.Len(t, proof, 2*32)
+	}
+
+	valid, err := VerifyClaim(root, &claims[0])
+	assert.Nil(t, err)
+	assert.True(t, valid)
+}
+
+func TestVerifyClaimSingleToken(t *testing.T) {
+	distro := distribution.NewDistribution()
+	for i, earner := range tests.TestAddresses[:3] {
+		err := distro.Set(earner, tests.TestTokens[i], big.NewInt(int64(i+1)))
+		assert.Nil(t, err)
+	}
+
+	root, claims := getTestClaims(t, distro)
+	results, err := VerifyClaimBatch(root, claims)
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, true}, results)
+	for _, claim := range claims {
+		assert.Len(t, claim.EarnerTreeProof, 2*32)
+		assert.Empty(t, claim.TokenTreeProofs[0])
+	}
+}
+
+func TestVerifyEarnerTokenRoot(t *testing.T) {
+	root, claims := getTestClaims(t, getClaimProofTestDistribution(t))
+	for i := range claims {
+		assert.Nil(t, VerifyEarnerTokenRoot(&claims[i]))
+	}
+
+	// a forged earner token root is caught without the account tree
+	forged := claims[2]
+	forged.EarnerLeaf.EarnerTokenRoot[0] ^= 0xff
+	err := VerifyEarnerTokenRoot(&forged)
+	assert.ErrorIs(t, err, ErrEarnerTokenRootMismatch)
+	assert.ErrorContains(t, err, forged.TokenLeaves[0].Token.Hex())
+	valid, err := VerifyClaim(root, &forged)
+	assert.Nil(t, err)
+	assert.False(t, valid)
+
+	// a single tampered token leaf reconstructs a different root
+	tampered := claims[3]
+	tampered.TokenLeaves = append([]ClaimProofTokenLeaf{}, tampered.TokenLeaves...)
+	last := len(tampered.TokenLeaves) - 1
+	tampered.TokenLeaves[last].CumulativeEarnings = new(big.Int).Add(tampered.TokenLeaves[last].CumulativeEarnings, big.NewInt(1))
+	err = VerifyEarnerTokenRoot(&tampered)
+	assert.ErrorIs(t, err, ErrEarnerTokenRootMismatch)
+	assert.ErrorContains(t, err, tampered.TokenLeaves[last].Token.Hex())
+
+	malformed := claims[0]
+	malformed.TokenIndices = malformed.TokenIndices[1:]
+	assert.ErrorIs(t, VerifyEarnerTokenRoot(&malformed), ErrMalformedClaim)
+	malformed = claims[0]
+	malformed.TokenLeaves = nil
+	malformed.TokenIndices = nil
+	malformed.TokenTreeProofs = nil
+	assert.ErrorIs(t, VerifyEarnerTokenRoot(&malformed), ErrMalformedClaim)
+}
@@ -0,0 +1,104 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// WithStringAmounts makes the line loaders keep plain decimal amounts as validated strings instead of
+// parsing them into big.Int, see Distribution.StringAmounts.
+func WithStringAmounts() Option {
+	return func(d *Distribution) {
+		d.StringAmounts = true
+	}
+}
+
+// Value returns the amount, parsing a decimal string kept by StringAmounts, so it should be used rather
+// than Int for the values of GetTokensForEarner and GetStart. The string is parsed into a new big.Int on
+// every call and is left in place, so reading amounts never writes to the distribution and may be done
+// concurrently; mutating the returned value of a string amount does not change the distribution.
+func (b *BigInt) Value() *big.Int {
+	if b.Int == nil && b.decimal != "" {
+		// the string was validated when it was stored
+		value, _ := new(big.Int).SetString(b.decimal, 10)
+		return value
+	}
+	return b.Int
+}
+
+// parse replaces a decimal string kept by StringAmounts with its parsed amount, so it is not parsed
+// again by every read
+func (b *BigInt) parse() {
+	b.Int = b.Value()
+	b.decimal = ""
+}
+
+// clone returns a copy of the amount that does not alias it, keeping a decimal string unparsed
+func (b *BigInt) clone() *BigInt {
+	if b == nil {
+		return &BigInt{Int: new(big.Int)}
+	}
+	if b.Int == nil && b.decimal != "" {
+		return &BigInt{decimal: b.decimal}
+	}
+	return &BigInt{Int: new(big.Int).Set(amountOrZero(b))}
+}
+
+// amountBytes32 returns the amount as the bytes32 stored in a token leaf, converting a decimal string
+// directly without allocating a big.Int. ErrAmountOverflow is returned for amounts that are negative
+// or larger than 256 bits, which Set rejects but a caller may have mutated an amount returned by Get.
+func amountBytes32(earner, token gethcommon.Address, amount *BigInt) ([32]byte, error) {
+	var amountU256 uint256.Int
+	if amount != nil && amount.Int == nil && amount.decimal != "" {
+		if err := amountU256.SetFromDecimal(amount.decimal); err != nil {
+			return [32]byte{}, fmt.Errorf("%w - earner: %s, token: %s, amount: %s", ErrAmountOverflow, earner.Hex(), token.Hex(), amount.decimal)
+		}
+		return amountU256.Bytes32(), nil
+	}
+
+	value := amountOrZero(amount)
+	if value.Sign() < 0 || amountU256.SetFromBig(value) {
+		return [32]byte{}, fmt.Errorf("%w - earner: %s, token: %s, amount: %s", ErrAmountOverflow, earner.Hex(), token.Hex(), value.String())
+	}
+	return amountU256.Bytes32(), nil
+}
+
+// parseDecimalAmount validates a plain decimal amount for StringAmounts, returning it without leading
+// zeros and whether it is zero. ok is false for amounts in any other notation and for amounts that do
+// not fit in 256 bits, which are left to parseAmount and Set to parse or reject.
+func parseDecimalAmount(amount string) (decimal string, zero bool, ok bool) {
+	if amount == "" || strings.TrimLeft(amount, "0123456789") != "" {
+		return "", false, false
+	}
+	var amountU256 uint256.Int
+	if err := amountU256.SetFromDecimal(amount); err != nil {
+		return "", false, false
+	}
+	decimal = strings.TrimLeft(amount, "0")
+	if decimal == "" {
+		decimal = "0"
+	}
+	return decimal, amountU256.IsZero(), true
+}
+
+// MaxDecimal128Amount is the largest amount AmountDecimal128 encodes, the largest value of a
+// DECIMAL(38, 0) column, which is the widest decimal a 16 byte Parquet or BigQuery column holds.
+var MaxDecimal128Amount, _ = new(big.Int).SetString(strings.Repeat("9", 38), 10)
+
+// AmountDecimal128 returns the cumulative amount as the 16 byte big endian two's complement integer of a
+// Parquet FIXED_LEN_BYTE_ARRAY(16) DECIMAL(38, 0) column. ok is false when the amount cannot be parsed,
+// is negative or is larger than MaxDecimal128Amount, amounts of tokens with many decimals can be, so
+// these have to be exported some other way, as the decimal string for example.
+func (e *EarnerLine) AmountDecimal128() (decimal [16]byte, ok bool) {
+	amount, err := e.CumulativeAmountBigInt()
+	if err != nil || amount.Sign() < 0 || amount.Cmp(MaxDecimal128Amount) > 0 {
+		return [16]byte{}, false
+	}
+	// non negative amounts below 2^127 have the same two's complement and unsigned encodings
+	amount.FillBytes(decimal[:])
+	return decimal, true
+}
@@ -0,0 +1,190 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringAmounts(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+
+	parsed := distribution.NewDistribution()
+	err := parsed.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.NoError(t, err)
+	stored := distribution.NewDistribution(distribution.WithStringAmounts())
+	err = stored.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.NoError(t, err)
+
+	// the root is computed from the strings without parsing them
+	root, err := stored.ComputeRoot()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotDistributionRoot, hex.EncodeToString(root))
+	accountTree, _, err := stored.Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotDistributionRoot, hex.EncodeToString(accountTree.Root()))
+
+	storedJSON, err := json.Marshal(stored)
+	assert.NoError(t, err)
+	parsedJSON, err := json.Marshal(parsed)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(parsedJSON), string(storedJSON))
+
+	assert.True(t, stored.Equal(parsed))
+	assert.Equal(t, parsed.TokenTotals(), stored.TokenTotals())
+	assert.True(t, stored.Clone().StringAmounts)
+}
+
+// TestStringAmountsConcurrentReads is meant to be run with -race, which reports any write to the
+// distribution while its amounts are read.
+func TestStringAmountsConcurrentReads(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+	parsed := distribution.NewDistribution()
+	err := parsed.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.NoError(t, err)
+	d := distribution.NewDistribution(distribution.WithStringAmounts())
+	err = d.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.NoError(t, err)
+
+	earner := d.Earners()[0]
+	token := d.TokensForEarner(earner)[0]
+	expected, _ := parsed.Get(earner, token)
+
+	// the workers read the same amount at once and only assert once they are done, since the assertions
+	// synchronize on t and would hide a race
+	amounts := make([]*big.Int, 8)
+	var wg sync.WaitGroup
+	for worker := range amounts {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			amounts[worker], _ = d.Get(earner, token)
+		}(worker)
+	}
+	wg.Wait()
+	for _, amount := range amounts {
+		assert.Equal(t, expected, amount)
+	}
+	assert.Equal(t, parsed.TokenTotals(), d.TokenTotals())
+}
+
+func TestStringAmountsNotations(t *testing.T) {
+	earner := tests.TestAddresses[0].Hex()
+	lines := []*distribution.EarnerLine{
+		{Earner: earner, Token: tests.TestTokens[0].Hex(), CumulativeAmount: "0042"},
+		{Earner: earner, Token: tests.TestTokens[1].Hex(), CumulativeAmount: "2.690822691e+27"},
+		{Earner: earner, Token: tests.TestTokens[2].Hex(), CumulativeAmount: "0x10"},
+		{Earner: earner, Token: tests.TestTokens[3].Hex(), CumulativeAmount: "000"},
+		{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "7"},
+	}
+	scientific, _ := new(big.Int).SetString("2690822691000000000000000000", 10)
+	expected := []*big.Int{big.NewInt(42), scientific, big.NewInt(16), big.NewInt(0)}
+
+	parsed := distribution.NewDistribution()
+	assert.NoError(t, parsed.LoadLines(lines))
+	stored := distribution.NewDistribution(distribution.WithStringAmounts())
+	assert.NoError(t, stored.LoadLines(lines))
+
+	expectedRoot, err := parsed.Root()
+	assert.NoError(t, err)
+	root, err := stored.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+
+	data, err := json.Marshal(stored)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `:42,`)
+
+	for i, amount := range expected {
+		actual, found := stored.Get(tests.TestAddresses[0], tests.TestTokens[i])
+		assert.True(t, found)
+		assert.Equal(t, 0, amount.Cmp(actual), "token %d", i)
+	}
+
+	// zero amounts are skipped without being parsed
+	omitted := distribution.NewDistribution(distribution.WithStringAmounts())
+	omitted.OmitZeroAmounts = true
+	assert.NoError(t, omitted.LoadLines(lines))
+	assert.Equal(t, 4, omitted.Len())
+
+	// an amount parsed by Get is stored, so mutating it changes the root like in the default mode
+	amount, _ := stored.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	amount.SetInt64(8)
+	amount, _ = parsed.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	amount.SetInt64(8)
+	expectedRoot, err = parsed.ComputeRoot()
+	assert.NoError(t, err)
+	root, err = stored.ComputeRoot()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+
+	// amounts that do not fit are still rejected
+	overflow := []*distribution.EarnerLine{
+		{Earner: earner, Token: tests.TestTokens[0].Hex(), CumulativeAmount: "115792089237316195423570985008687907853269984665640564039457584007913129639936"},
+	}
+	err = distribution.NewDistribution(distribution.WithStringAmounts()).LoadLines(overflow)
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+}
+
+func benchmarkLoadAndMerklize(b *testing.B, opts ...distribution.Option) {
+	lines := parseTestEarnerLines(b, getFullTestEarnerLines())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := distribution.NewDistribution(opts...)
+		if err := d.LoadLinesForSnapshot(lines, 1716681600000); err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := d.Merklize(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoadAndMerklize(b *testing.B) {
+	b.Run("big.Int", func(b *testing.B) {
+		benchmarkLoadAndMerklize(b)
+	})
+	b.Run("string", func(b *testing.B) {
+		benchmarkLoadAndMerklize(b, distribution.WithStringAmounts())
+	})
+}
+
+func TestAmountDecimal128(t *testing.T) {
+	// the largest amount of the test fixture has 37 digits and fits
+	line := &distribution.EarnerLine{CumulativeAmount: "1285714285714284560000000000000000000"}
+	decimal, ok := line.AmountDecimal128()
+	assert.True(t, ok)
+	assert.Equal(t, "00f79e9bc3b95201e5e04b9af6400000", hex.EncodeToString(decimal[:]))
+	amount, _ := line.CumulativeAmountBigInt()
+	assert.Equal(t, amount, new(big.Int).SetBytes(decimal[:]))
+
+	line.CumulativeAmount = "1"
+	decimal, ok = line.AmountDecimal128()
+	assert.True(t, ok)
+	assert.Equal(t, [16]byte{15: 1}, decimal)
+
+	line.CumulativeAmount = distribution.MaxDecimal128Amount.String()
+	decimal, ok = line.AmountDecimal128()
+	assert.True(t, ok)
+	assert.Equal(t, distribution.MaxDecimal128Amount, new(big.Int).SetBytes(decimal[:]))
+
+	// 39 digits do not fit a DECIMAL(38, 0), nor does the largest uint256
+	for _, amount := range []string{
+		new(big.Int).Add(distribution.MaxDecimal128Amount, big.NewInt(1)).String(),
+		"115792089237316195423570985008687907853269984665640564039457584007913129639935",
+		"-1",
+		"not a number",
+	} {
+		line.CumulativeAmount = amount
+		decimal, ok = line.AmountDecimal128()
+		assert.False(t, ok, amount)
+		assert.Equal(t, [16]byte{}, decimal)
+	}
+}
@@ -0,0 +1,193 @@
+package distribution
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+// DefaultArity is the number of children of each branch node when Distribution.Arity is not set,
+// the binary trees verified by the RewardsCoordinator.
+const DefaultArity = 2
+
+// WithArity sets the number of children of each branch node in the token and account trees, see Distribution.Arity.
+// Only ComputeRoot and GenerateArityProof honor an arity other than 2.
+func WithArity(arity int) Option {
+	return func(d *Distribution) {
+		d.Arity = arity
+	}
+}
+
+// arity returns the arity of the trees, DefaultArity when not set
+func (d *Distribution) arity() (int, error) {
+	if d.Arity == 0 {
+		return DefaultArity, nil
+	}
+	if d.Arity < 2 {
+		return 0, fmt.Errorf("%w, got %d", ErrInvalidArity, d.Arity)
+	}
+	return d.Arity, nil
+}
+
+// ArityProof is the proof of a token leaf through its earner's token tree and the account tree of a
+// distribution with any arity. Each level of a proof holds the arity - 1 siblings of the node in the
+// order of their indices, skipping the node itself, from the leaves up to the root.
+type ArityProof struct {
+	Arity           int
+	Earner          gethcommon.Address
+	EarnerIndex     uint64
+	EarnerTokenRoot []byte
+	EarnerTreeProof [][]byte
+	Token           gethcommon.Address
+	TokenIndex      uint64
+	Amount          *big.Int
+	TokenTreeProof  [][]byte
+}
+
+// GenerateArityProof returns the proof of an earner's token for the arity of the distribution. The trees
+// are rebuilt level by level for every proof, so this is meant for evaluating wider trees rather than for
+// generating the proofs of a whole distribution; binary trees are better proven with Merklize.
+func (d *Distribution) GenerateArityProof(earner, token gethcommon.Address) (*ArityProof, error) {
+	arity, err := d.arity()
+	if err != nil {
+		return nil, err
+	}
+	format, err := d.leafFormat()
+	if err != nil {
+		return nil, err
+	}
+	hashType := d.treeHashType()
+	if err := format.checkDomains(hashType.HashLength(), arity); err != nil {
+		return nil, err
+	}
+	if _, found := d.data.Get(earner); !found {
+		return nil, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+	}
+
+	accountHashes := make([][]byte, 0, d.data.Len())
+	proof := &ArityProof{Arity: arity, Earner: earner, Token: token}
+	found := false
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		address := accountPair.Key
+		tokenHashes := make([][]byte, 0, accountPair.Value.Len())
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			amount, err := amountBytes32(address, tokenPair.Key, tokenPair.Value)
+			if err != nil {
+				return nil, err
+			}
+			if address == earner && tokenPair.Key == token {
+				proof.TokenIndex = uint64(len(tokenHashes))
+				proof.Amount = new(big.Int).SetBytes(amount[:])
+				found = true
+			}
+			tokenHashes = append(tokenHashes, hashType.Hash(format.encodeTokenLeafBytes32(tokenPair.Key, amount)))
+		}
+
+		tokenLevels, err := buildLevels(tokenHashes, hashType, arity)
+		if err != nil {
+			return nil, fmt.Errorf("%w - earner: %s", err, address.Hex())
+		}
+		tokenRoot := tokenLevels[len(tokenLevels)-1][0]
+		if address == earner {
+			if !found {
+				return nil, fmt.Errorf("%w - earner: %s, token: %s", ErrTokenNotFound, earner.Hex(), token.Hex())
+			}
+			proof.EarnerIndex = uint64(len(accountHashes))
+			proof.EarnerTokenRoot = tokenRoot
+			proof.TokenTreeProof = levelsProof(tokenLevels, proof.TokenIndex, arity)
+		}
+		accountHashes = append(accountHashes, hashType.Hash(format.encodeAccountLeaf(address, tokenRoot)))
+	}
+
+	accountLevels, err := buildLevels(accountHashes, hashType, arity)
+	if err != nil {
+		return nil, err
+	}
+	proof.EarnerTreeProof = levelsProof(accountLevels, proof.EarnerIndex, arity)
+	return proof, nil
+}
+
+// VerifyArityProof checks an ArityProof against an account root, hashing with keccak256 and encoding
+// the leaves with CurrentVersion like HashLeaf. Malformed proofs are reported as invalid.
+func VerifyArityProof(root []byte, proof *ArityProof) bool {
+	if proof.Arity < 2 || proof.Amount == nil || proof.Amount.Sign() < 0 || proof.Amount.BitLen() > 256 || len(proof.EarnerTokenRoot) != 32 {
+		return false
+	}
+	hashType := keccak256.New()
+	tokenLeafHash := HashLeaf(EncodeTokenLeaf(proof.Token, proof.Amount))
+	tokenRoot, ok := arityProofRoot(tokenLeafHash, proof.TokenIndex, proof.TokenTreeProof, proof.Arity, hashType)
+	if !ok || !bytes.Equal(tokenRoot, proof.EarnerTokenRoot) {
+		return false
+	}
+	earnerLeafHash := HashLeaf(EncodeAccountLeaf(proof.Earner, proof.EarnerTokenRoot))
+	accountRoot, ok := arityProofRoot(earnerLeafHash, proof.EarnerIndex, proof.EarnerTreeProof, proof.Arity, hashType)
+	return ok && bytes.Equal(accountRoot, root)
+}
+
+// buildLevels returns every level of a tree from the hashed leaves up to the root, padding the leaves
+// with zero hashes to a power of the arity. Each branch node is the hash of its children concatenated
+// in order, so an arity of 2 gives the same nodes as the merkletree package.
+func buildLevels(hashes [][]byte, hashType merkletree.HashType, arity int) ([][][]byte, error) {
+	if len(hashes) == 0 {
+		return nil, ErrEmptyTree
+	}
+	width := 1
+	for width < len(hashes) {
+		width *= arity
+	}
+	level := make([][]byte, width)
+	copy(level, hashes)
+	zero := make([]byte, hashType.HashLength())
+	for i := len(hashes); i < width; i++ {
+		level[i] = zero
+	}
+
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		parents := make([][]byte, len(level)/arity)
+		for i := range parents {
+			parents[i] = hashType.Hash(level[i*arity : (i+1)*arity]...)
+		}
+		levels = append(levels, parents)
+		level = parents
+	}
+	return levels, nil
+}
+
+// levelsProof returns the siblings of a leaf at every level below the root
+func levelsProof(levels [][][]byte, index uint64, arity int) [][]byte {
+	proof := make([][]byte, 0, (len(levels)-1)*(arity-1))
+	for _, level := range levels[:len(levels)-1] {
+		first := index - index%uint64(arity)
+		for i := first; i < first+uint64(arity); i++ {
+			if i != index {
+				proof = append(proof, level[i])
+			}
+		}
+		index /= uint64(arity)
+	}
+	return proof
+}
+
+// arityProofRoot returns the root reached from a leaf hash, or false if the proof or index is malformed
+func arityProofRoot(leafHash []byte, index uint64, proof [][]byte, arity int, hashType merkletree.HashType) ([]byte, bool) {
+	if len(proof)%(arity-1) != 0 {
+		return nil, false
+	}
+	node := leafHash
+	children := make([][]byte, arity)
+	for level := 0; level < len(proof)/(arity-1); level++ {
+		siblings := proof[level*(arity-1) : (level+1)*(arity-1)]
+		position := int(index % uint64(arity))
+		copy(children, siblings[:position])
+		children[position] = node
+		copy(children[position+1:], siblings[position:])
+		node = hashType.Hash(children...)
+		index /= uint64(arity)
+	}
+	return node, index == 0
+}
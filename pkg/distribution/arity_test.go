@@ -0,0 +1,115 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func getArityTestDistribution(t *testing.T, arity int) *distribution.Distribution {
+	d := distribution.NewDistribution(distribution.WithArity(arity))
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	return d
+}
+
+func TestArityBinary(t *testing.T) {
+	d := getArityTestDistribution(t, 2)
+	root, err := d.ComputeRoot()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotDistributionRoot, hex.EncodeToString(root))
+	root, err = d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotDistributionRoot, hex.EncodeToString(root))
+
+	// the proofs are the same as the ones of the merkle trees
+	for _, earner := range d.Earners()[:10] {
+		accountProof, earnerIndex, err := d.AccountProof(earner)
+		assert.NoError(t, err)
+		for _, token := range d.TokensForEarner(earner) {
+			proof, err := d.GenerateArityProof(earner, token)
+			assert.NoError(t, err)
+			assert.Equal(t, earnerIndex, proof.EarnerIndex)
+			assert.Equal(t, accountProof, proof.EarnerTreeProof)
+
+			tokenProof, tokenIndex, err := d.TokenProof(earner, token)
+			assert.NoError(t, err)
+			assert.Equal(t, tokenIndex, proof.TokenIndex)
+			assert.Equal(t, tokenProof, proof.TokenTreeProof)
+			assert.True(t, distribution.VerifyArityProof(root, proof))
+		}
+	}
+}
+
+func TestArityWider(t *testing.T) {
+	for _, arity := range []int{3, 4} {
+		d := getArityTestDistribution(t, arity)
+		root, err := d.Root()
+		assert.NoError(t, err)
+		assert.NotEqual(t, snapshotDistributionRoot, hex.EncodeToString(root))
+
+		_, _, err = d.Merklize()
+		assert.ErrorIs(t, err, distribution.ErrUnsupportedArity)
+
+		for _, earner := range d.Earners() {
+			for _, token := range d.TokensForEarner(earner) {
+				proof, err := d.GenerateArityProof(earner, token)
+				assert.NoError(t, err)
+				assert.True(t, distribution.VerifyArityProof(root, proof), "arity %d, earner %s, token %s", arity, earner.Hex(), token.Hex())
+			}
+		}
+
+		proof, err := d.GenerateArityProof(d.Earners()[0], d.TokensForEarner(d.Earners()[0])[0])
+		assert.NoError(t, err)
+		if arity == 4 {
+			// the earners fit in 4 levels of 4 children, against 8 levels of 2
+			assert.Len(t, proof.EarnerTreeProof, 4*3)
+		}
+
+		// tampering with the amount, the index or the arity invalidates the proof
+		proof.Amount = new(big.Int).Add(proof.Amount, big.NewInt(1))
+		assert.False(t, distribution.VerifyArityProof(root, proof))
+		proof.Amount.Sub(proof.Amount, big.NewInt(1))
+		proof.EarnerIndex++
+		assert.False(t, distribution.VerifyArityProof(root, proof))
+		proof.EarnerIndex--
+		proof.Arity = 2
+		assert.False(t, distribution.VerifyArityProof(root, proof))
+		proof.Arity = arity
+		assert.True(t, distribution.VerifyArityProof(root, proof))
+	}
+}
+
+func TestAritySingleLeaf(t *testing.T) {
+	d := distribution.NewDistribution(distribution.WithArity(4))
+	assert.NoError(t, d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1)))
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	proof, err := d.GenerateArityProof(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.NoError(t, err)
+	assert.Empty(t, proof.EarnerTreeProof)
+	assert.Empty(t, proof.TokenTreeProof)
+	assert.Equal(t, distribution.HashLeaf(distribution.EncodeAccountLeaf(tests.TestAddresses[0], proof.EarnerTokenRoot)), root)
+	assert.True(t, distribution.VerifyArityProof(root, proof))
+}
+
+func TestArityErrors(t *testing.T) {
+	d := GetTestDistribution()
+	d.Arity = 1
+	_, err := d.ComputeRoot()
+	assert.ErrorIs(t, err, distribution.ErrInvalidArity)
+	_, _, err = d.Merklize()
+	assert.ErrorIs(t, err, distribution.ErrInvalidArity)
+
+	d.Arity = 4
+	_, err = d.GenerateArityProof(common.HexToAddress("0x01"), tests.TestTokens[0])
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+	_, err = d.GenerateArityProof(tests.TestAddresses[4], tests.TestTokens[1])
+	assert.ErrorIs(t, err, distribution.ErrTokenNotFound)
+}
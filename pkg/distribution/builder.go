@@ -0,0 +1,61 @@
+package distribution
+
+import (
+	"math/big"
+	"sync"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// DistributionBuilder collects amounts in any order, then sorts them into a Distribution.
+// Unlike Distribution it is safe for concurrent use.
+type DistributionBuilder struct {
+	mu      sync.Mutex
+	amounts map[gethcommon.Address]map[gethcommon.Address]*big.Int
+}
+
+func NewDistributionBuilder() *DistributionBuilder {
+	return &DistributionBuilder{
+		amounts: make(map[gethcommon.Address]map[gethcommon.Address]*big.Int),
+	}
+}
+
+// Set sets the amount for an earner and token, overwriting any previous amount.
+// The amount is copied, and validated when the distribution is built.
+func (b *DistributionBuilder) Set(earner, token gethcommon.Address, amount *big.Int) {
+	var amountCopy *big.Int
+	if amount != nil {
+		amountCopy = new(big.Int).Set(amount)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tokens, found := b.amounts[earner]
+	if !found {
+		tokens = make(map[gethcommon.Address]*big.Int)
+		b.amounts[earner] = tokens
+	}
+	tokens[token] = amountCopy
+}
+
+// Build sorts the collected amounts and sets them on a new distribution, returning the
+// same errors as Set for invalid amounts. The builder can keep being used afterwards.
+func (b *DistributionBuilder) Build() (*Distribution, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	amounts := make(map[gethcommon.Address]map[gethcommon.Address]*big.Int, len(b.amounts))
+	for earner, tokens := range b.amounts {
+		tokensCopy := make(map[gethcommon.Address]*big.Int, len(tokens))
+		for token, amount := range tokens {
+			if amount != nil {
+				amount = new(big.Int).Set(amount)
+			}
+			tokensCopy[token] = amount
+		}
+		amounts[earner] = tokensCopy
+	}
+
+	return newDistributionFromAmounts(amounts)
+}
@@ -0,0 +1,66 @@
+package distribution_test
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistributionBuilderConcurrentSet(t *testing.T) {
+	builder := distribution.NewDistributionBuilder()
+
+	// set every entry of the complete test distribution in reverse order across goroutines
+	var wg sync.WaitGroup
+	for i := len(tests.TestAddresses) - 1; i >= 0; i-- {
+		for j := len(tests.TestTokens) - 1; j >= 0; j-- {
+			wg.Add(1)
+			go func(i, j int) {
+				defer wg.Done()
+				builder.Set(tests.TestAddresses[i], tests.TestTokens[j], big.NewInt(int64(j+i+2)))
+			}(i, j)
+		}
+	}
+	wg.Wait()
+
+	built, err := builder.Build()
+	assert.NoError(t, err)
+
+	expected := GetCompleteTestDistribution()
+	assert.True(t, expected.Equal(built))
+
+	root, err := built.Root()
+	assert.NoError(t, err)
+	expectedRoot, err := expected.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedRoot, root)
+}
+
+func TestDistributionBuilderInvalidAmounts(t *testing.T) {
+	builder := distribution.NewDistributionBuilder()
+	builder.Set(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(-1))
+	_, err := builder.Build()
+	assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+
+	builder = distribution.NewDistributionBuilder()
+	builder.Set(tests.TestAddresses[1], tests.TestTokens[0], new(big.Int).Lsh(big.NewInt(1), 256))
+	_, err = builder.Build()
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+}
+
+func TestDistributionBuilderCopiesAmounts(t *testing.T) {
+	builder := distribution.NewDistributionBuilder()
+	amount := big.NewInt(1)
+	builder.Set(tests.TestAddresses[0], tests.TestTokens[0], amount)
+	amount.SetInt64(2)
+
+	built, err := builder.Build()
+	assert.NoError(t, err)
+
+	fetched, found := built.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(1), fetched)
+}
@@ -0,0 +1,33 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// Claimable returns the amount of a token the earner can still claim, which is the cumulative amount
+// minus the amount already claimed on-chain. It is zero when the earner is up to date, a nil
+// alreadyClaimed is treated as zero, and ErrClaimedExceedsCumulative is returned if more has been
+// claimed than the distribution holds.
+func (d *Distribution) Claimable(earner, token gethcommon.Address, alreadyClaimed *big.Int) (*big.Int, error) {
+	tokens, found := d.data.Get(earner)
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+	}
+	amount, found := tokens.Get(token)
+	if !found {
+		return nil, fmt.Errorf("%w - earner: %s, token: %s", ErrTokenNotFound, earner.Hex(), token.Hex())
+	}
+
+	cumulative := amountOrZero(amount)
+	if alreadyClaimed == nil {
+		alreadyClaimed = big.NewInt(0)
+	}
+	if alreadyClaimed.Cmp(cumulative) > 0 {
+		return nil, fmt.Errorf("%w - earner: %s, token: %s, claimed: %s, cumulative: %s",
+			ErrClaimedExceedsCumulative, earner.Hex(), token.Hex(), alreadyClaimed, cumulative)
+	}
+	return new(big.Int).Sub(cumulative, alreadyClaimed), nil
+}
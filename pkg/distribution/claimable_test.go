@@ -0,0 +1,48 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimable(t *testing.T) {
+	d := GetTestDistribution()
+	earner, token := tests.TestAddresses[1], tests.TestTokens[2]
+	cumulative, found := d.Get(earner, token)
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(4), cumulative)
+
+	for _, c := range []struct {
+		claimed  *big.Int
+		expected *big.Int
+	}{
+		{nil, big.NewInt(4)},
+		{big.NewInt(0), big.NewInt(4)},
+		{big.NewInt(1), big.NewInt(3)},
+		{big.NewInt(4), big.NewInt(0)},
+	} {
+		claimable, err := d.Claimable(earner, token, c.claimed)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, c.expected.Cmp(claimable), claimable.String())
+	}
+
+	// the result does not alias the distribution
+	claimable, err := d.Claimable(earner, token, big.NewInt(0))
+	assert.NoError(t, err)
+	claimable.SetInt64(100)
+	amount, _ := d.Get(earner, token)
+	assert.Equal(t, big.NewInt(4), amount)
+
+	_, err = d.Claimable(earner, token, big.NewInt(5))
+	assert.ErrorIs(t, err, distribution.ErrClaimedExceedsCumulative)
+	assert.ErrorContains(t, err, "claimed: 5, cumulative: 4")
+
+	_, err = d.Claimable(tests.TestAddresses[4], tests.TestTokens[1], nil)
+	assert.ErrorIs(t, err, distribution.ErrTokenNotFound)
+	_, err = d.Claimable(tests.TestTokens[0], token, nil)
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+}
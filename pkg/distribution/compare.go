@@ -0,0 +1,71 @@
+package distribution
+
+import (
+	"math/big"
+	"sort"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// EarnerTokenDiff describes an earner/token pair whose amount differs between two distributions.
+// Amount or OtherAmount is nil when the pair is missing from that side.
+type EarnerTokenDiff struct {
+	Earner      gethcommon.Address
+	Token       gethcommon.Address
+	Amount      *big.Int
+	OtherAmount *big.Int
+}
+
+// Equal returns whether both distributions hold the same amounts for the same earner/token pairs.
+func (d *Distribution) Equal(other *Distribution) bool {
+	return len(d.Diff(other)) == 0
+}
+
+// Diff returns the earner/token pairs whose amounts differ between the distributions, including
+// pairs present in only one of them, sorted by earner and token.
+func (d *Distribution) Diff(other *Distribution) []EarnerTokenDiff {
+	diffs := make([]EarnerTokenDiff, 0)
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			amount := amountOrZero(tokenPair.Value)
+			otherAmount, found := other.Get(accountPair.Key, tokenPair.Key)
+			if !found {
+				diffs = append(diffs, EarnerTokenDiff{Earner: accountPair.Key, Token: tokenPair.Key, Amount: amount})
+				continue
+			}
+			if otherAmount == nil {
+				otherAmount = new(big.Int)
+			}
+			if amount.Cmp(otherAmount) != 0 {
+				diffs = append(diffs, EarnerTokenDiff{Earner: accountPair.Key, Token: tokenPair.Key, Amount: amount, OtherAmount: otherAmount})
+			}
+		}
+	}
+
+	for accountPair := other.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			if _, found := d.Get(accountPair.Key, tokenPair.Key); !found {
+				diffs = append(diffs, EarnerTokenDiff{Earner: accountPair.Key, Token: tokenPair.Key, OtherAmount: amountOrZero(tokenPair.Value)})
+			}
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		if c := CompareAddresses(diffs[i].Earner, diffs[j].Earner); c != 0 {
+			return c < 0
+		}
+		return CompareAddresses(diffs[i].Token, diffs[j].Token) < 0
+	})
+	return diffs
+}
+
+// amountOrZero returns the stored amount, treating nil as zero
+func amountOrZero(amount *BigInt) *big.Int {
+	if amount == nil {
+		return new(big.Int)
+	}
+	if value := amount.Value(); value != nil {
+		return value
+	}
+	return new(big.Int)
+}
@@ -0,0 +1,53 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqual(t *testing.T) {
+	assert.True(t, GetTestDistribution().Equal(GetTestDistribution()))
+	assert.False(t, GetTestDistribution().Equal(GetCompleteTestDistribution()))
+	assert.True(t, distribution.NewDistribution().Equal(distribution.NewDistribution()))
+}
+
+func TestEqualComparesValues(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+
+	other := distribution.NewDistribution()
+	err = other.Set(tests.TestAddresses[0], tests.TestTokens[0], new(big.Int).SetBytes([]byte{1}))
+	assert.NoError(t, err)
+
+	assert.True(t, d.Equal(other))
+}
+
+func TestDiff(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	err = d.Set(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(2))
+	assert.NoError(t, err)
+	err = d.Set(tests.TestAddresses[2], tests.TestTokens[0], big.NewInt(3))
+	assert.NoError(t, err)
+
+	other := distribution.NewDistribution()
+	err = other.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	err = other.Set(tests.TestAddresses[0], tests.TestTokens[1], big.NewInt(4))
+	assert.NoError(t, err)
+	err = other.Set(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(5))
+	assert.NoError(t, err)
+
+	diffs := d.Diff(other)
+	assert.Equal(t, []distribution.EarnerTokenDiff{
+		{Earner: tests.TestAddresses[0], Token: tests.TestTokens[1], OtherAmount: big.NewInt(4)},
+		{Earner: tests.TestAddresses[1], Token: tests.TestTokens[0], Amount: big.NewInt(2), OtherAmount: big.NewInt(5)},
+		{Earner: tests.TestAddresses[2], Token: tests.TestTokens[0], Amount: big.NewInt(3)},
+	}, diffs)
+}
@@ -0,0 +1,112 @@
+package distribution
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseEarnerLinesCSV reads earner lines from CSV with a header row naming the earner, token,
+// snapshot and cumulative_amount columns in any order. The snapshot column is optional.
+// Amounts are validated the same way as CumulativeAmountBigInt. Rows that cannot be read or parsed are
+// reported with a ParseError holding their line number in the input, the header being line 1.
+func ParseEarnerLinesCSV(r io.Reader) ([]*EarnerLine, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := map[string]int{"snapshot": -1}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"earner", "token", "cumulative_amount"} {
+		if _, found := columns[name]; !found {
+			return nil, fmt.Errorf("csv header is missing the %s column", name)
+		}
+	}
+
+	lines := make([]*EarnerLine, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var csvErr *csv.ParseError
+		if errors.As(err, &csvErr) {
+			return nil, &ParseError{LineNumber: csvErr.StartLine, Err: fmt.Errorf("failed to read csv: %w", err)}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read csv: %w", err)
+		}
+		row, _ := reader.FieldPos(0)
+
+		line := &EarnerLine{
+			Earner:           record[columns["earner"]],
+			Token:            record[columns["token"]],
+			CumulativeAmount: record[columns["cumulative_amount"]],
+		}
+		if _, err := line.CumulativeAmountBigInt(); err != nil {
+			err = fmt.Errorf("failed to parse csv row %d: %w", row, err)
+			return nil, newParseError(row, "", &fieldError{field: "cumulative_amount", rawValue: line.CumulativeAmount, err: err})
+		}
+		if i := columns["snapshot"]; i >= 0 && record[i] != "" {
+			if line.Snapshot, err = parseSnapshot(record[i]); err != nil {
+				err = fmt.Errorf("failed to parse csv row %d: %w", row, err)
+				return nil, newParseError(row, "", &fieldError{field: "snapshot", rawValue: record[i], err: err})
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// WriteCSV writes one earner, token, cumulative_amount row per entry, in the same order as Merklize,
+// with amounts as plain decimal integers. The output can be read back with ParseEarnerLinesCSV.
+func (d *Distribution) WriteCSV(w io.Writer) error {
+	return d.writeCSV(w, false)
+}
+
+// WriteCSVWithIndices behaves like WriteCSV with additional account_index and token_index columns.
+// The distribution must be merklized first.
+func (d *Distribution) WriteCSVWithIndices(w io.Writer) error {
+	if !d.isMerklized() {
+		return ErrNotMerklized
+	}
+	return d.writeCSV(w, true)
+}
+
+func (d *Distribution) writeCSV(w io.Writer, withIndices bool) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"earner", "token", "cumulative_amount"}
+	if withIndices {
+		header = append(header, "account_index", "token_index")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			record := []string{accountPair.Key.Hex(), tokenPair.Key.Hex(), formatAmount(amountOrZero(tokenPair.Value))}
+			if withIndices {
+				accountIndex, _ := d.GetAccountIndex(accountPair.Key)
+				tokenIndex, _ := d.GetTokenIndex(accountPair.Key, tokenPair.Key)
+				record = append(record, strconv.FormatUint(accountIndex, 10), strconv.FormatUint(tokenIndex, 10))
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,99 @@
+package distribution_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEarnerLinesCSV(t *testing.T) {
+	jsonLines := `{"earner":"0xce50089021676aa2cbac4cc72a2aa655b495bc73","token":"0x94373a4919b3240d86ea41593d5eba789fef3848","snapshot":1716681600000,"cumulative_amount":"6102895758009265"}
+{"earner":"0xd37f737629e0ddad7fc8adc7247d2e79c0296c35","token":"0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9","snapshot":1716681600000,"cumulative_amount":"2.690822691e+27"}`
+
+	// columns in a different order, with quoted fields
+	csvLines := `cumulative_amount,token,earner,snapshot
+6102895758009265,0x94373a4919b3240d86ea41593d5eba789fef3848,0xce50089021676aa2cbac4cc72a2aa655b495bc73,1716681600000
+"2.690822691e+27","0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9","0xd37f737629e0ddad7fc8adc7247d2e79c0296c35","1716681600000"
+`
+
+	lines, err := distribution.ParseEarnerLinesCSV(strings.NewReader(csvLines))
+	assert.NoError(t, err)
+	assert.Equal(t, parseTestEarnerLines(t, jsonLines), lines)
+}
+
+func TestParseEarnerLinesCSVErrors(t *testing.T) {
+	_, err := distribution.ParseEarnerLinesCSV(strings.NewReader("earner,token\n0x1,0x2\n"))
+	assert.ErrorContains(t, err, "cumulative_amount")
+
+	_, err = distribution.ParseEarnerLinesCSV(strings.NewReader("earner,token,cumulative_amount\n0x1,0x2,1\n0x1,0x3,1.5\n"))
+	assert.ErrorContains(t, err, "row 3")
+
+	var parseErr *distribution.ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 3, parseErr.LineNumber)
+		assert.Equal(t, "cumulative_amount", parseErr.Field)
+		assert.Equal(t, "1.5", parseErr.RawValue)
+	}
+
+	_, err = distribution.ParseEarnerLinesCSV(strings.NewReader("earner,token,snapshot,cumulative_amount\n0x1,0x2,1716681600,1\n"))
+	assert.ErrorIs(t, err, distribution.ErrInvalidSnapshot)
+	assert.ErrorContains(t, err, "row 2")
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 2, parseErr.LineNumber)
+		assert.Equal(t, "snapshot", parseErr.Field)
+	}
+
+	_, err = distribution.ParseEarnerLinesCSV(strings.NewReader("earner,token,cumulative_amount\n0x1,0x2,1\n0x1,0x3\n"))
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 3, parseErr.LineNumber)
+	}
+}
+
+func TestWriteCSVRoundTrip(t *testing.T) {
+	d := GetTestDistribution()
+
+	var buf bytes.Buffer
+	err := d.WriteCSV(&buf)
+	assert.NoError(t, err)
+
+	lines, err := distribution.ParseEarnerLinesCSV(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 15)
+
+	parsed, err := distribution.NewDistributionFromUnsortedLines(lines)
+	assert.NoError(t, err)
+	assert.True(t, d.Equal(parsed))
+
+	// the output is deterministic
+	var first, second bytes.Buffer
+	assert.NoError(t, d.WriteCSV(&first))
+	assert.NoError(t, d.WriteCSV(&second))
+	assert.Equal(t, first.String(), second.String())
+}
+
+func TestWriteCSVWithIndices(t *testing.T) {
+	d := GetTestDistribution()
+
+	err := d.WriteCSVWithIndices(&bytes.Buffer{})
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	_, _, err = d.Merklize()
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = d.WriteCSVWithIndices(&buf)
+	assert.NoError(t, err)
+
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, "earner,token,cumulative_amount,account_index,token_index", rows[0])
+	// the last earner holds a single token
+	assert.Equal(t, tests.TestAddresses[4].Hex()+","+tests.TestTokens[0].Hex()+",5,4,0", rows[len(rows)-1])
+
+	lines, err := distribution.ParseEarnerLinesCSV(&buf)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 15)
+}
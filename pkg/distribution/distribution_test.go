@@ -1,16 +1,21 @@
 package distribution_test
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
 	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -69,13 +74,118 @@ func FuzzSetAndGet(f *testing.F) {
 	})
 }
 
+// fuzzEntrySize is the number of bytes decoded into each entry by FuzzLoadAndMerklize:
+// an earner byte, a token byte and a three byte amount
+const fuzzEntrySize = 5
+
+func FuzzLoadAndMerklize(f *testing.F) {
+	f.Add([]byte{1, 1, 0, 0, 1})
+	// the same earner with several tokens, and the same earner and token twice
+	f.Add([]byte{7, 3, 0, 0, 1, 7, 1, 0, 0, 2, 7, 2, 0, 0, 3, 2, 2, 1, 0, 0, 2, 2, 0, 0, 9})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		type entry struct {
+			earner, token common.Address
+			amount        *big.Int
+		}
+		entries := make([]entry, 0, len(data)/fuzzEntrySize)
+		for i := 0; i+fuzzEntrySize <= len(data); i += fuzzEntrySize {
+			// spread the byte over the address so both the first and last bytes affect the order
+			earner := common.Address{19: data[i]}
+			earner[0] = data[i] ^ 0x5a
+			token := common.Address{19: data[i+1]}
+			token[0] = data[i+1] ^ 0xa5
+			entries = append(entries, entry{earner, token, new(big.Int).SetBytes(data[i+2 : i+fuzzEntrySize])})
+		}
+		if len(entries) == 0 {
+			return
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			if c := entries[i].earner.Cmp(entries[j].earner); c != 0 {
+				return c < 0
+			}
+			return entries[i].token.Cmp(entries[j].token) < 0
+		})
+
+		d := distribution.NewDistribution()
+		expected := make(map[[2]common.Address]*big.Int)
+		for _, e := range entries {
+			err := d.Set(e.earner, e.token, e.amount)
+			assert.NoError(t, err)
+			expected[[2]common.Address{e.earner, e.token}] = e.amount
+		}
+
+		_, _, err := d.Merklize()
+		assert.NoError(t, err)
+		assert.Equal(t, len(expected), d.Len())
+
+		for key, amount := range expected {
+			accountIndex, found := d.GetAccountIndex(key[0])
+			assert.True(t, found)
+			tokenIndex, found := d.GetTokenIndex(key[0], key[1])
+			assert.True(t, found)
+
+			earner, token, entryAmount, found := d.EntryByIndex(accountIndex, tokenIndex)
+			assert.True(t, found)
+			assert.Equal(t, key[0], earner)
+			assert.Equal(t, key[1], token)
+			assert.Equal(t, 0, amount.Cmp(entryAmount))
+		}
+	})
+}
+
 func TestSetNilAmount(t *testing.T) {
 	d := distribution.NewDistribution()
 	err := d.Set(common.Address{}, common.Address{}, nil)
 	assert.NoError(t, err)
 
-	_, found := d.Get(common.Address{}, common.Address{})
+	amount, found := d.Get(common.Address{}, common.Address{})
 	assert.True(t, found)
+	assert.Equal(t, 0, amount.Sign())
+
+	_, _, err = d.Merklize()
+	assert.NoError(t, err)
+}
+
+func FuzzSetAmountSign(f *testing.F) {
+	f.Add(int64(-1))
+	f.Add(int64(0))
+	f.Add(int64(1))
+
+	f.Fuzz(func(t *testing.T, amountFuzz int64) {
+		// scale the amount past int64 so large negative values are covered too
+		amount := new(big.Int).Mul(big.NewInt(amountFuzz), big.NewInt(amountFuzz))
+		if amountFuzz < 0 {
+			amount.Neg(amount)
+		}
+
+		d := distribution.NewDistribution()
+		err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], amount)
+
+		_, found := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+		if amount.Sign() < 0 {
+			assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+			assert.False(t, found)
+		} else {
+			assert.NoError(t, err)
+			assert.True(t, found)
+		}
+	})
+}
+
+func TestSetAmountOverflow(t *testing.T) {
+	d := distribution.NewDistribution()
+
+	maxAmount := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], maxAmount)
+	assert.NoError(t, err)
+
+	overflowAmount := new(big.Int).Lsh(big.NewInt(1), 256)
+	err = d.Set(tests.TestAddresses[0], tests.TestTokens[1], overflowAmount)
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+
+	_, found := d.Get(tests.TestAddresses[0], tests.TestTokens[1])
+	assert.False(t, found)
 }
 
 func TestSetAddressesInNonAlphabeticalOrder(t *testing.T) {
@@ -122,6 +232,228 @@ func TestGetUnset(t *testing.T) {
 	assert.False(t, found)
 }
 
+func TestGetAliasesAndGetCopy(t *testing.T) {
+	d := GetTestDistribution()
+
+	// Get returns the stored amount, so mutating it changes the distribution
+	amount, found := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.True(t, found)
+	amount.SetInt64(100)
+	aliased, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(100), aliased)
+
+	copied, found := d.GetCopy(tests.TestAddresses[0], tests.TestTokens[1])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(2), copied)
+	copied.SetInt64(200)
+	original, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[1])
+	assert.Equal(t, big.NewInt(2), original)
+
+	copied, found = d.GetCopy(tests.TestAddresses[4], tests.TestTokens[1])
+	assert.False(t, found)
+	assert.Equal(t, big.NewInt(0), copied)
+
+	err := d.Set(tests.TestAddresses[4], tests.TestTokens[1], nil)
+	assert.NoError(t, err)
+	copied, found = d.GetCopy(tests.TestAddresses[4], tests.TestTokens[1])
+	assert.True(t, found)
+	assert.Equal(t, 0, copied.Sign())
+}
+
+func TestCompareAddresses(t *testing.T) {
+	a := common.HexToAddress("0xa000000000000000000000000000000000000000")
+	b := common.HexToAddress("0xb000000000000000000000000000000000000000")
+
+	// the checksummed strings sort the other way round
+	assert.Equal(t, "0xa000000000000000000000000000000000000000", a.Hex())
+	assert.Equal(t, "0xB000000000000000000000000000000000000000", b.Hex())
+	assert.Greater(t, a.Hex(), b.Hex())
+
+	assert.Equal(t, -1, distribution.CompareAddresses(a, b))
+	assert.Equal(t, 1, distribution.CompareAddresses(b, a))
+	assert.Equal(t, 0, distribution.CompareAddresses(a, a))
+	assert.Equal(t, -1, distribution.CompareAddresses(common.HexToAddress("0x01"), common.HexToAddress("0x0100")))
+
+	// checksummed lines are loaded in byte order
+	lines := []*distribution.EarnerLine{
+		{Earner: b.Hex(), Token: b.Hex(), CumulativeAmount: "4"},
+		{Earner: a.Hex(), Token: b.Hex(), CumulativeAmount: "2"},
+		{Earner: b.Hex(), Token: a.Hex(), CumulativeAmount: "3"},
+		{Earner: a.Hex(), Token: a.Hex(), CumulativeAmount: "1"},
+	}
+	d := distribution.NewDistribution()
+	err := d.LoadLines(lines)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{a, b}, d.Earners())
+	assert.Equal(t, []common.Address{a, b}, d.TokensForEarner(b))
+	amount, _ := d.Get(b, a)
+	assert.Equal(t, big.NewInt(3), amount)
+
+	err = d.Set(common.HexToAddress("0x01"), a, big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrAddressNotInOrder)
+}
+
+func TestEarnerAmounts(t *testing.T) {
+	d := GetTestDistribution()
+	for i, earner := range tests.TestAddresses {
+		amounts := d.EarnerAmounts(earner)
+		assert.Len(t, amounts, len(tests.TestTokens)-i)
+		for _, token := range d.TokensForEarner(earner) {
+			expected, found := d.Get(earner, token)
+			assert.True(t, found)
+			assert.Equal(t, expected, amounts[token])
+		}
+	}
+
+	// the map and amounts are copies
+	amounts := d.EarnerAmounts(tests.TestAddresses[0])
+	amounts[tests.TestTokens[0]].SetInt64(100)
+	delete(amounts, tests.TestTokens[1])
+	assert.Equal(t, big.NewInt(1), d.EarnerAmounts(tests.TestAddresses[0])[tests.TestTokens[0]])
+	assert.Len(t, d.EarnerAmounts(tests.TestAddresses[0]), len(tests.TestTokens))
+
+	assert.Nil(t, d.EarnerAmounts(tests.TestTokens[0]))
+}
+
+func TestAdd(t *testing.T) {
+	d := distribution.NewDistribution()
+
+	err := d.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[1], big.NewInt(2))
+	assert.NoError(t, err)
+	err = d.Add(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(3))
+	assert.NoError(t, err)
+
+	// accumulating into pairs that are not the newest is allowed
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(10))
+	assert.NoError(t, err)
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[1], nil)
+	assert.NoError(t, err)
+
+	amount, found := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(11), amount)
+	amount, found = d.Get(tests.TestAddresses[0], tests.TestTokens[1])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(2), amount)
+	assert.Equal(t, []common.Address{tests.TestAddresses[0], tests.TestAddresses[1]}, d.Earners())
+
+	// inserting new pairs out of order still fails
+	err = d.Add(common.HexToAddress("0x01"), tests.TestTokens[0], big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrAddressNotInOrder)
+	err = d.Add(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	err = d.Add(tests.TestAddresses[2], tests.TestTokens[1], big.NewInt(1))
+	assert.NoError(t, err)
+	err = d.Add(tests.TestAddresses[2], tests.TestTokens[0], big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrTokenNotInOrder)
+}
+
+func TestAddDoesNotMutateReturnedAmounts(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+
+	before, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(2))
+	assert.NoError(t, err)
+	assert.Equal(t, big.NewInt(1), before)
+
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(-1))
+	assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(4))
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[0], max)
+	assert.NoError(t, err)
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+}
+
+func TestAddAfterMerklize(t *testing.T) {
+	d := GetTestDistribution()
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	err = d.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	_, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+}
+
+func TestClone(t *testing.T) {
+	d := GetTestDistribution()
+	d.MaxLineBytes = 1024
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	clone := d.Clone()
+	assert.True(t, d.Equal(clone))
+	assert.Equal(t, d.Earners(), clone.Earners())
+	assert.Equal(t, 1024, clone.MaxLineBytes)
+
+	// the clone starts without the cached trees
+	_, found := clone.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+
+	err = clone.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(100))
+	assert.NoError(t, err)
+	amount, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(1), amount)
+
+	// mutating an amount returned by Get does not affect the other distribution
+	amount, _ = clone.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	amount.SetInt64(200)
+	original, _ := d.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(2), original)
+
+	// the original is still merklized
+	_, found = d.GetAccountIndex(tests.TestAddresses[0])
+	assert.True(t, found)
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+}
+
+func TestLenAndCounts(t *testing.T) {
+	d := GetTestDistribution()
+
+	// earner i holds len(tests.TestTokens)-i tokens
+	expectedLen := 0
+	for i := range tests.TestAddresses {
+		expectedLen += len(tests.TestTokens) - i
+	}
+	for _, merklize := range []bool{false, true} {
+		if merklize {
+			_, _, err := d.Merklize()
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, expectedLen, d.Len())
+		assert.Equal(t, len(tests.TestAddresses), d.EarnerCount())
+		for i, earner := range tests.TestAddresses {
+			assert.Equal(t, len(tests.TestTokens)-i, d.TokenCount(earner))
+		}
+	}
+	assert.Equal(t, 0, d.TokenCount(common.HexToAddress("0x01")))
+
+	empty := distribution.NewDistribution()
+	assert.Equal(t, 0, empty.Len())
+	assert.Equal(t, 0, empty.EarnerCount())
+}
+
+func TestLeafSalts(t *testing.T) {
+	// RewardsCoordinator: EARNER_LEAF_SALT = 0, TOKEN_LEAF_SALT = 1
+	assert.Equal(t, byte(0), distribution.EarnerLeafSalt)
+	assert.Equal(t, byte(1), distribution.TokenLeafSalt)
+	assert.Equal(t, []byte{distribution.EarnerLeafSalt}, distribution.EARNER_LEAF_SALT)
+	assert.Equal(t, []byte{distribution.TokenLeafSalt}, distribution.TOKEN_LEAF_SALT)
+
+	accountLeaf := distribution.EncodeAccountLeaf(tests.TestAddresses[0], make([]byte, 32))
+	assert.Equal(t, distribution.EarnerLeafSalt, accountLeaf[0])
+	tokenLeaf := distribution.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(1))
+	assert.Equal(t, distribution.TokenLeafSalt, tokenLeaf[0])
+}
+
 func TestEncodeAccountLeaf(t *testing.T) {
 	for i := 0; i < len(tests.TestAddresses); i++ {
 		testRoot, _ := hex.DecodeString(tests.TestRootsString[i])
@@ -132,6 +464,38 @@ func TestEncodeAccountLeaf(t *testing.T) {
 	}
 }
 
+func TestVerifyAccountLeaf(t *testing.T) {
+	testRoot, _ := hex.DecodeString(tests.TestRootsString[0])
+	leaf := distribution.EncodeAccountLeaf(tests.TestAddresses[0], testRoot)
+	assert.True(t, distribution.VerifyAccountLeaf(leaf, tests.TestAddresses[0], testRoot))
+	assert.NoError(t, distribution.CheckAccountLeaf(leaf, tests.TestAddresses[0], testRoot))
+
+	corrupt := func(i int) []byte {
+		corrupted := append([]byte{}, leaf...)
+		corrupted[i] ^= 0xff
+		return corrupted
+	}
+	for _, tc := range []struct {
+		leaf     []byte
+		expected string
+	}{
+		{corrupt(0), "salt: 255, expected: 0"},
+		{corrupt(1), "earner: 0xfAf7a45E049c96769360FafEF7ccfC130Dc22Ab6, expected: " + tests.TestAddresses[0].Hex()},
+		{corrupt(52), "earner token root: 0x"},
+		{leaf[:52], "length: 52, expected: 53"},
+	} {
+		assert.False(t, distribution.VerifyAccountLeaf(tc.leaf, tests.TestAddresses[0], testRoot))
+		err := distribution.CheckAccountLeaf(tc.leaf, tests.TestAddresses[0], testRoot)
+		assert.ErrorIs(t, err, distribution.ErrLeafMismatch)
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	// the leaf of another earner or root does not match either
+	assert.False(t, distribution.VerifyAccountLeaf(leaf, tests.TestAddresses[1], testRoot))
+	assert.False(t, distribution.VerifyAccountLeaf(leaf, tests.TestAddresses[0], make([]byte, 32)))
+	assert.False(t, distribution.VerifyAccountLeaf(leaf, tests.TestAddresses[0], testRoot[:31]))
+}
+
 func TestEncodeTokenLeaf(t *testing.T) {
 	for i := 0; i < len(tests.TestTokens); i++ {
 		testAmount, _ := new(big.Int).SetString(tests.TestAmountsString[i], 10)
@@ -142,6 +506,20 @@ func TestEncodeTokenLeaf(t *testing.T) {
 	}
 }
 
+func TestEncodeTokenLeafPadding(t *testing.T) {
+	maxAmount := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	for _, amount := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(0x1234), maxAmount} {
+		leaf := distribution.EncodeTokenLeaf(tests.TestTokens[0], amount)
+		assert.Len(t, leaf, 1+20+32)
+		assert.Equal(t, distribution.TOKEN_LEAF_SALT[0], leaf[0])
+		assert.Equal(t, tests.TestTokens[0][:], leaf[1:21])
+		assert.Equal(t, common.LeftPadBytes(amount.Bytes(), 32), leaf[21:])
+	}
+
+	leaf := distribution.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(1))
+	assert.Equal(t, "0000000000000000000000000000000000000000000000000000000000000001", hex.EncodeToString(leaf[21:]))
+}
+
 func TestGetAccountIndexBeforeMerklization(t *testing.T) {
 	d := GetTestDistribution()
 
@@ -158,6 +536,103 @@ func TestGetTokenIndexBeforeMerklization(t *testing.T) {
 	assert.Equal(t, uint64(0), tokenIndex)
 }
 
+func TestGetWithIndices(t *testing.T) {
+	d := GetTestDistribution()
+
+	// before merklization the amounts are found but the indices are zero
+	amount, accountIndex, tokenIndex, found := d.GetWithIndices(tests.TestAddresses[1], tests.TestTokens[2])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(4), amount)
+	assert.Equal(t, uint64(0), accountIndex)
+	assert.Equal(t, uint64(0), tokenIndex)
+
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+	for _, earner := range append(d.Earners(), common.HexToAddress("0x01")) {
+		for _, token := range tests.TestTokens {
+			amount, accountIndex, tokenIndex, found := d.GetWithIndices(earner, token)
+
+			expectedAmount, expectedFound := d.Get(earner, token)
+			assert.Equal(t, expectedFound, found)
+			assert.Equal(t, expectedAmount, amount)
+			if !found {
+				assert.Equal(t, uint64(0), accountIndex)
+				assert.Equal(t, uint64(0), tokenIndex)
+				continue
+			}
+			expectedAccountIndex, _ := d.GetAccountIndex(earner)
+			expectedTokenIndex, _ := d.GetTokenIndex(earner, token)
+			assert.Equal(t, expectedAccountIndex, accountIndex)
+			assert.Equal(t, expectedTokenIndex, tokenIndex)
+		}
+	}
+
+	// the amount aliases the distribution like Get
+	amount, _, _, _ = d.GetWithIndices(tests.TestAddresses[4], tests.TestTokens[0])
+	same, _ := d.Get(tests.TestAddresses[4], tests.TestTokens[0])
+	assert.Same(t, same, amount)
+}
+
+func TestAccountAndTokenIndices(t *testing.T) {
+	d := GetTestDistribution()
+	unknown := common.HexToAddress("0x01")
+	earners := []common.Address{tests.TestAddresses[3], unknown, tests.TestAddresses[0], tests.TestAddresses[3]}
+
+	indices, found := d.AccountIndices(earners)
+	assert.Equal(t, []uint64{0, 0, 0, 0}, indices)
+	assert.Equal(t, []bool{false, false, false, false}, found)
+
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	indices, found = d.AccountIndices(earners)
+	assert.Equal(t, []uint64{3, 0, 0, 3}, indices)
+	assert.Equal(t, []bool{true, false, true, true}, found)
+	for i, earner := range earners {
+		index, ok := d.GetAccountIndex(earner)
+		assert.Equal(t, index, indices[i])
+		assert.Equal(t, ok, found[i])
+	}
+
+	tokens := []common.Address{tests.TestTokens[1], tests.TestTokens[4], tests.TestTokens[0]}
+	indices, found = d.TokenIndices(tests.TestAddresses[1], tokens)
+	assert.Equal(t, []uint64{1, 0, 0}, indices)
+	assert.Equal(t, []bool{true, false, true}, found)
+	for i, token := range tokens {
+		index, ok := d.GetTokenIndex(tests.TestAddresses[1], token)
+		assert.Equal(t, index, indices[i])
+		assert.Equal(t, ok, found[i])
+	}
+
+	indices, found = d.TokenIndices(unknown, tokens)
+	assert.Equal(t, []uint64{0, 0, 0}, indices)
+	assert.Equal(t, []bool{false, false, false}, found)
+}
+
+func BenchmarkAccountIndices(b *testing.B) {
+	d := getLargeTestDistribution(100_000)
+	_, _, _ = d.Merklize()
+	earners := d.Earners()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.AccountIndices(earners)
+	}
+}
+
+func BenchmarkGetAccountIndex(b *testing.B) {
+	d := getLargeTestDistribution(100_000)
+	_, _, _ = d.Merklize()
+	earners := d.Earners()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		indices := make([]uint64, len(earners))
+		found := make([]bool, len(earners))
+		for j, earner := range earners {
+			indices[j], found[j] = d.GetAccountIndex(earner)
+		}
+	}
+}
+
 func TestMerklize(t *testing.T) {
 	d := GetTestDistribution()
 
@@ -197,6 +672,195 @@ func TestMerklize(t *testing.T) {
 	}
 }
 
+func TestEarnersAndTokensForEarner(t *testing.T) {
+	d := GetTestDistribution()
+
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	earners := d.Earners()
+	assert.Equal(t, tests.TestAddresses, earners)
+
+	for i, earner := range earners {
+		accountIndex, found := d.GetAccountIndex(earner)
+		assert.True(t, found)
+		assert.Equal(t, uint64(i), accountIndex)
+
+		tokens := d.TokensForEarner(earner)
+		assert.Equal(t, tests.TestTokens[:len(tests.TestTokens)-i], tokens)
+
+		for j, token := range tokens {
+			tokenIndex, found := d.GetTokenIndex(earner, token)
+			assert.True(t, found)
+			assert.Equal(t, uint64(j), tokenIndex)
+		}
+	}
+
+	assert.Nil(t, d.TokensForEarner(common.Address{}))
+}
+
+func TestAllTokens(t *testing.T) {
+	d := GetTestDistribution()
+	assert.Equal(t, tests.TestTokens, d.AllTokens())
+
+	// tokens missing from the first earner are still listed, in order
+	d = distribution.NewDistribution()
+	assert.Empty(t, d.AllTokens())
+	assert.Nil(t, d.Set(tests.TestAddresses[0], tests.TestTokens[2], big.NewInt(1)))
+	assert.Nil(t, d.Set(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(1)))
+	assert.Nil(t, d.Set(tests.TestAddresses[1], tests.TestTokens[2], big.NewInt(1)))
+	assert.Nil(t, d.Set(tests.TestAddresses[2], tests.TestTokens[1], big.NewInt(1)))
+	assert.Equal(t, tests.TestTokens[:3], d.AllTokens())
+
+	// the fixture has many earners sharing a few tokens
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+	d = distribution.NewDistribution()
+	assert.Nil(t, d.LoadLinesForSnapshot(lines, 1716681600000))
+	distinct := make(map[common.Address]bool)
+	for _, line := range lines {
+		if line.Snapshot == 1716681600000 {
+			distinct[common.HexToAddress(line.Token)] = true
+		}
+	}
+	tokens := d.AllTokens()
+	assert.Len(t, tokens, len(distinct))
+	for i, token := range tokens {
+		assert.True(t, distinct[token])
+		if i > 0 {
+			assert.Negative(t, distribution.CompareAddresses(tokens[i-1], token))
+		}
+	}
+}
+
+func TestMerklizeEmpty(t *testing.T) {
+	d := distribution.NewDistribution()
+
+	_, _, err := d.Merklize()
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+	_, err = d.Root()
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+	_, err = d.GenerateAllProofs()
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+	_, err = d.AccountTreeDepth()
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+}
+
+func TestMerklizeSingleEntry(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(7))
+	assert.NoError(t, err)
+
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	// both trees are a single leaf, so each root is the hash of its leaf
+	tokenRoot := crypto.Keccak256(distribution.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(7)))
+	assert.Equal(t, tokenRoot, tokenTrees[tests.TestAddresses[0]].Root())
+	assert.Equal(t, crypto.Keccak256(distribution.EncodeAccountLeaf(tests.TestAddresses[0], tokenRoot)), accountTree.Root())
+}
+
+func TestMerklizeAmountOverflow(t *testing.T) {
+	d := GetTestDistribution()
+
+	// amounts returned by Get can be mutated past the bounds checked by Set
+	amount, _ := d.Get(tests.TestAddresses[2], tests.TestTokens[1])
+	amount.Lsh(amount, 256)
+
+	_, _, err := d.Merklize()
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+	assert.ErrorContains(t, err, tests.TestAddresses[2].Hex())
+	assert.ErrorContains(t, err, tests.TestTokens[1].Hex())
+
+	_, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+
+	// the largest amount in the test earner lines fits
+	large, _ := new(big.Int).SetString("428571428571423900000000000000000000", 10)
+	amount.Set(large)
+	_, _, err = d.Merklize()
+	assert.NoError(t, err)
+}
+
+func TestMerklizeContextCancelled(t *testing.T) {
+	d := GetTestDistribution()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := d.MerklizeContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+}
+
+func TestMerklizeContextCancelledMidway(t *testing.T) {
+	d := getLargeTestDistribution(50_000)
+
+	start := time.Now()
+	_, _, err := d.Clone().Merklize()
+	assert.NoError(t, err)
+	full := time.Since(start)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(full/10, cancel)
+
+	start = time.Now()
+	_, _, err = d.MerklizeContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), full/2)
+
+	_, found := d.GetAccountIndex(d.Earners()[0])
+	assert.False(t, found)
+	_, err = d.AccountTreeDepth()
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+}
+
+func TestMerklizeCached(t *testing.T) {
+	d := GetTestDistribution()
+
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	cachedAccountTree, cachedTokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+	assert.Same(t, accountTree, cachedAccountTree)
+	assert.Equal(t, tokenTrees, cachedTokenTrees)
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, accountTree.Root(), root)
+}
+
+func TestSetAfterMerklize(t *testing.T) {
+	d := GetTestDistribution()
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+
+	_, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.True(t, found)
+
+	err = d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(100))
+	assert.NoError(t, err)
+
+	// the indices are cleared until the next merklization
+	_, found = d.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+	_, found = d.GetTokenIndex(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.False(t, found)
+
+	newRoot, err := d.Root()
+	assert.NoError(t, err)
+	assert.NotEqual(t, root, newRoot)
+
+	accountIndex, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.True(t, found)
+	assert.Equal(t, uint64(0), accountIndex)
+}
+
 func TestNewDistributionWithData(t *testing.T) {
 	distro, err := distribution.NewDistributionWithData(tests.TestJsonDistribution)
 	assert.Nil(t, err)
@@ -209,13 +873,50 @@ func TestNewDistributionWithData(t *testing.T) {
 	assert.Len(t, tokens[addr].Data, 1)
 }
 
+func TestMarshalJSONRoundTrip(t *testing.T) {
+	d := GetTestDistribution()
+	large, _ := new(big.Int).SetString("58775510204081430400000000000", 10)
+	err := d.Set(tests.TestAddresses[4], tests.TestTokens[1], large)
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(d)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "58775510204081430400000000000")
+
+	decoded := distribution.NewDistribution()
+	err = json.Unmarshal(data, decoded)
+	assert.NoError(t, err)
+	assert.True(t, d.Equal(decoded))
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	decodedRoot, err := decoded.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, root, decodedRoot)
+}
+
+func TestUnmarshalJSONUnsortedAndQuoted(t *testing.T) {
+	data := []byte(`{
+		"` + tests.TestAddresses[1].Hex() + `": {"` + tests.TestTokens[0].Hex() + `": "58775510204081430400000000000"},
+		"` + tests.TestAddresses[0].Hex() + `": {"` + tests.TestTokens[1].Hex() + `": 2, "` + tests.TestTokens[0].Hex() + `": 1}
+	}`)
+
+	d, err := distribution.NewDistributionWithData(data)
+	assert.NoError(t, err)
+	assert.Equal(t, tests.TestAddresses[:2], d.Earners())
+	assert.Equal(t, tests.TestTokens[:2], d.TokensForEarner(tests.TestAddresses[0]))
+
+	amount, found := d.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Equal(t, "58775510204081430400000000000", amount.String())
+}
+
 func TestNewDistributionWithClaimDataLines(t *testing.T) {
 	allLines := getFullTestEarnerLines()
 	earnerLines := strings.Split(allLines, "\n")
 
 	earners := make([]*distribution.EarnerLine, 0)
 	for _, e := range earnerLines {
-		e = e
 		if e == "" {
 			continue
 		}
@@ -226,10 +927,310 @@ func TestNewDistributionWithClaimDataLines(t *testing.T) {
 	}
 	assert.Len(t, earners, 603)
 
+	// the fixture mixes snapshots, which is rejected without loading anything
 	distro := distribution.NewDistribution()
 	err := distro.LoadLines(earners)
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+	assert.ErrorContains(t, err, "snapshot: 1712102400000, expected: 1716681600000")
+	assert.Zero(t, distro.Len())
+	assert.Zero(t, distro.Snapshot)
 
+	err = distro.LoadLinesForSnapshot(earners, 1716681600000)
 	assert.Nil(t, err)
+	assert.Equal(t, uint64(1716681600000), distro.Snapshot)
+	assert.Equal(t, uint64(1716681600000), distro.Clone().Snapshot)
+
+	// later loads must be from the same snapshot
+	err = distro.LoadLinesForSnapshot(earners, 1716422400000)
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+	assert.Equal(t, uint64(1716681600000), distro.Snapshot)
+}
+
+func TestCalculationEndTimestamp(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.Nil(t, err)
+	// the loaders leave it for the caller
+	assert.Zero(t, distro.CalculationEndTimestamp)
+
+	// an end timestamp earlier than the snapshot is rejected without changing the distribution
+	err = distro.SetCalculationEndTimestamp(1716681599999)
+	assert.ErrorIs(t, err, distribution.ErrEndBeforeSnapshot)
+	assert.ErrorContains(t, err, "snapshot: 1716681600000, calculation end timestamp: 1716681599999")
+	assert.Zero(t, distro.CalculationEndTimestamp)
+
+	err = distro.SetCalculationEndTimestamp(1716681600000)
+	assert.Nil(t, err)
+	err = distro.SetCalculationEndTimestamp(1716768000000)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1716768000000), distro.CalculationEndTimestamp)
+	assert.Equal(t, uint64(1716768000000), distro.Clone().CalculationEndTimestamp)
+
+	// lines from a snapshot after the end timestamp are rejected before anything is loaded
+	distro = distribution.NewDistribution()
+	distro.CalculationEndTimestamp = 1716422400000
+	err = distro.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.ErrorIs(t, err, distribution.ErrEndBeforeSnapshot)
+	assert.Zero(t, distro.Len())
+}
+
+func TestNewDistributionFromEarnerLineArray(t *testing.T) {
+	data := fmt.Sprintf(`[
+		{"earner":"%s","token":"%s","snapshot":1716681600000,"cumulative_amount":"4"},
+		{"earner":"%s","token":"%s","snapshot":1716681600000,"cumulative_amount":"2"},
+		{"earner":"%s","token":"%s","snapshot":1716681600000,"cumulative_amount":"3"},
+		{"earner":"%s","token":"%s","snapshot":1716681600000,"cumulative_amount":"1"}
+	]`,
+		tests.TestAddresses[1].Hex(), tests.TestTokens[1].Hex(),
+		tests.TestAddresses[0].Hex(), tests.TestTokens[1].Hex(),
+		tests.TestAddresses[1].Hex(), tests.TestTokens[0].Hex(),
+		tests.TestAddresses[0].Hex(), tests.TestTokens[0].Hex())
+
+	distro, err := distribution.NewDistributionFromEarnerLineArray([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1716681600000), distro.Snapshot)
+	assert.Equal(t, 4, distro.Len())
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 2; j++ {
+			amount, found := distro.Get(tests.TestAddresses[i], tests.TestTokens[j])
+			assert.True(t, found)
+			assert.Equal(t, big.NewInt(int64(2*i+j+1)), amount)
+		}
+	}
+
+	empty, err := distribution.NewDistributionFromEarnerLineArray([]byte(`[]`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, empty.Len())
+
+	_, err = distribution.NewDistributionFromEarnerLineArray([]byte(`{"earner":"0x01"}`))
+	assert.ErrorContains(t, err, "failed to unmarshal earner lines")
+	_, err = distribution.NewDistributionFromEarnerLineArray([]byte(`[null]`))
+	assert.ErrorContains(t, err, "failed to parse line 1: earner line is null")
+	_, err = distribution.NewDistributionFromEarnerLineArray([]byte(`[{"earner":"0x01","token":"0x02","snapshot":1716681600000,"cumulative_amount":"5"},` +
+		`{"earner":"0x01","token":"0x03","snapshot":1712102400000,"cumulative_amount":"5"}]`))
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+	assert.ErrorContains(t, err, "line: 2,")
+	var parseErr *distribution.ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 2, parseErr.LineNumber)
+		assert.Equal(t, "snapshot", parseErr.Field)
+	}
+
+	_, err = distribution.NewDistributionFromEarnerLineArray([]byte(`[{"earner":"0x01","token":"0x02","snapshot":1716681600000,"cumulative_amount":"5"},` +
+		`{"earner":"0x01","token":"0x03","snapshot":1716681600000,"cumulative_amount":5}]`))
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 2, parseErr.LineNumber)
+		assert.Equal(t, "cumulative_amount", parseErr.Field)
+	}
+}
+
+func TestNewDistributionFromUnsortedLines(t *testing.T) {
+	lines := []*distribution.EarnerLine{
+		{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[1].Hex(), CumulativeAmount: "4"},
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[1].Hex(), CumulativeAmount: "2"},
+		{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "3"},
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "1"},
+	}
+
+	distro, err := distribution.NewDistributionFromUnsortedLines(lines)
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 2; j++ {
+			amount, found := distro.Get(tests.TestAddresses[i], tests.TestTokens[j])
+			assert.True(t, found)
+			assert.Equal(t, big.NewInt(int64(2*i+j+1)), amount)
+		}
+	}
+
+	_, _, err = distro.Merklize()
+	assert.NoError(t, err)
+}
+
+func TestNewDistributionFromUnsortedLinesDuplicate(t *testing.T) {
+	lines := []*distribution.EarnerLine{
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "1"},
+		{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "2"},
+		{Earner: strings.ToLower(tests.TestAddresses[0].Hex()), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "3"},
+	}
+
+	_, err := distribution.NewDistributionFromUnsortedLines(lines)
+	assert.ErrorIs(t, err, distribution.ErrDuplicateEntry)
+	assert.ErrorContains(t, err, tests.TestAddresses[0].Hex())
+	assert.ErrorContains(t, err, tests.TestTokens[0].Hex())
+	var parseErr *distribution.ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, 3, parseErr.LineNumber)
+	}
+}
+
+func TestNewDistributionFromUnsortedLinesParseError(t *testing.T) {
+	for _, tc := range []struct {
+		amount string
+		err    error
+	}{
+		{"1.5", nil},
+		{"-1", distribution.ErrNegativeAmount},
+		{"0x1" + strings.Repeat("0", 64), distribution.ErrAmountOverflow},
+	} {
+		lines := []*distribution.EarnerLine{
+			{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "1"},
+			{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: tc.amount},
+		}
+		_, err := distribution.NewDistributionFromUnsortedLines(lines)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err)
+		}
+		var parseErr *distribution.ParseError
+		if assert.ErrorAs(t, err, &parseErr, tc.amount) {
+			assert.Equal(t, 2, parseErr.LineNumber)
+			assert.Equal(t, "cumulative_amount", parseErr.Field)
+			assert.Equal(t, tc.amount, parseErr.RawValue)
+		}
+	}
+}
+
+func TestLoadLinesDuplicateLastWins(t *testing.T) {
+	// enough lines for the sort not to fall back to insertion sort, which is stable anyway
+	lines := make([]*distribution.EarnerLine, 0, 100)
+	for i := 1; i <= 100; i++ {
+		earner := tests.TestAddresses[i%2]
+		if i%5 == 0 {
+			earner = tests.TestAddresses[2]
+		}
+		lines = append(lines, &distribution.EarnerLine{Earner: earner.Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: strconv.Itoa(i)})
+	}
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLines(lines)
+	assert.NoError(t, err)
+	for earner, expected := range map[common.Address]int64{tests.TestAddresses[0]: 98, tests.TestAddresses[1]: 99, tests.TestAddresses[2]: 100} {
+		amount, found := distro.Get(earner, tests.TestTokens[0])
+		assert.True(t, found)
+		assert.Equal(t, big.NewInt(expected), amount, "earner %s", earner.Hex())
+	}
+}
+
+func TestLoadLinesStrictDuplicate(t *testing.T) {
+	lines := []*distribution.EarnerLine{
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "1"},
+		{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "2"},
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "3"},
+	}
+
+	err := distribution.NewDistribution().LoadLinesStrict(lines)
+	assert.ErrorIs(t, err, distribution.ErrDuplicateEntry)
+	assert.ErrorContains(t, err, tests.TestAddresses[0].Hex())
+	assert.ErrorContains(t, err, tests.TestTokens[0].Hex())
+	assert.Regexp(t, "amounts: [13] and [13]", err.Error())
+
+	// the permissive loader keeps overwriting
+	err = distribution.NewDistribution().LoadLines(lines)
+	assert.NoError(t, err)
+}
+
+func TestLoadLinesStrict(t *testing.T) {
+	earners := parseTestEarnerLines(t, getFullTestEarnerLines())
+
+	matching := make([]*distribution.EarnerLine, 0, len(earners))
+	for _, e := range earners {
+		if e.Snapshot == 1716681600000 {
+			matching = append(matching, e)
+		}
+	}
+	err := distribution.NewDistribution().LoadLinesStrict(matching)
+	assert.NoError(t, err)
+
+	// the strict loader checks the snapshot too
+	err = distribution.NewDistribution().LoadLinesStrict(earners)
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+}
+
+func TestLoadLinesForSnapshot(t *testing.T) {
+	earners := parseTestEarnerLines(t, getFullTestEarnerLines())
+
+	counts := map[uint64]int{}
+	for _, e := range earners {
+		counts[e.Snapshot]++
+	}
+	assert.Equal(t, map[uint64]int{1716681600000: 596, 1716422400000: 6, 1712102400000: 1}, counts)
+
+	for snapshot, count := range counts {
+		distro := distribution.NewDistribution()
+		skipped, err := distro.LoadLinesForSnapshotWithSkipped(earners, snapshot)
+		assert.NoError(t, err)
+		assert.Equal(t, len(earners)-count, skipped)
+
+		loaded := 0
+		for _, e := range earners {
+			_, found := distro.Get(common.HexToAddress(e.Earner), common.HexToAddress(e.Token))
+			assert.Equal(t, e.Snapshot == snapshot, found)
+			if found {
+				loaded++
+			}
+		}
+		assert.Equal(t, count, loaded)
+	}
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesForSnapshot(earners, 1712102400000)
+	assert.NoError(t, err)
+	assert.Len(t, distro.Earners(), 1)
+}
+
+func TestToEarnerLines(t *testing.T) {
+	previous := GetTestDistribution()
+	current := previous.Clone()
+	large, ok := new(big.Int).SetString("2690822690822645700000000000", 10)
+	assert.True(t, ok)
+	err := current.Add(tests.TestAddresses[0], tests.TestTokens[0], large)
+	assert.NoError(t, err)
+	err = current.Add(tests.TestAddresses[2], tests.TestTokens[1], big.NewInt(5))
+	assert.NoError(t, err)
+	err = current.Add(tests.TestAddresses[4], tests.TestTokens[1], big.NewInt(7))
+	assert.NoError(t, err)
+
+	delta, err := current.Subtract(previous)
+	assert.NoError(t, err)
+
+	// unchanged pairs have a zero delta and are omitted
+	lines := delta.ToEarnerLines(1716681600000)
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "2690822690822645700000000000", lines[0].CumulativeAmount)
+
+	// the lines encode and load back into the same delta
+	raw := make([]string, 0, len(lines))
+	for _, line := range lines {
+		assert.Equal(t, uint64(1716681600000), line.Snapshot)
+		encoded, err := json.Marshal(line)
+		assert.NoError(t, err)
+		raw = append(raw, string(encoded))
+	}
+	loaded := distribution.NewDistribution()
+	err = loaded.LoadLines(parseTestEarnerLines(t, strings.Join(raw, "\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1716681600000), loaded.Snapshot)
+
+	delta.PruneZero()
+	assert.True(t, delta.Equal(loaded))
+	assert.Empty(t, distribution.NewDistribution().ToEarnerLines(1716681600000))
+}
+
+// parseTestEarnerLines unmarshals newline delimited earner lines, skipping blank lines
+func parseTestEarnerLines(t testing.TB, raw string) []*distribution.EarnerLine {
+	earners := make([]*distribution.EarnerLine, 0)
+	for _, e := range strings.Split(raw, "\n") {
+		if e == "" {
+			continue
+		}
+		earner := &distribution.EarnerLine{}
+		err := json.Unmarshal([]byte(e), earner)
+		assert.Nil(t, err)
+		earners = append(earners, earner)
+	}
+	return earners
 }
 
 func TestDistributionLineUnMarshal(t *testing.T) {
@@ -239,6 +1240,193 @@ func TestDistributionLineUnMarshal(t *testing.T) {
 	err := json.Unmarshal([]byte(line), earner)
 	assert.Nil(t, err)
 	fmt.Printf("Earner line: %+v\n", earner)
+
+	amount, err := earner.CumulativeAmountBigInt()
+	assert.Nil(t, err)
+	assert.Equal(t, "2690822691000000000000000000", amount.String())
+}
+
+func TestEarnerLineSnapshot(t *testing.T) {
+	for _, snapshot := range []int64{1716681600000, 1712102400000} {
+		line := fmt.Sprintf(`{"earner":"0xd37f737629e0ddad7fc8adc7247d2e79c0296c35","token":"0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9","snapshot":%d,"cumulative_amount":"1"}`, snapshot)
+
+		earner := &distribution.EarnerLine{}
+		err := json.Unmarshal([]byte(line), earner)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(snapshot), earner.Snapshot)
+		assert.Equal(t, time.UnixMilli(snapshot).UTC(), earner.SnapshotTime())
+		assert.Equal(t, 0, earner.SnapshotTime().Hour())
+	}
+
+	assert.Equal(t, "2024-05-26", (&distribution.EarnerLine{Snapshot: 1716681600000}).SnapshotTime().Format(time.DateOnly))
+}
+
+func TestEarnerLineInvalidSnapshot(t *testing.T) {
+	for _, snapshot := range []string{"1716681600", "-1716681600000", "1.7166816e+12"} {
+		line := `{"earner":"0xd37f737629e0ddad7fc8adc7247d2e79c0296c35","token":"0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9","snapshot":` + snapshot + `,"cumulative_amount":"1"}`
+
+		earner := &distribution.EarnerLine{}
+		err := json.Unmarshal([]byte(line), earner)
+		assert.ErrorIs(t, err, distribution.ErrInvalidSnapshot, snapshot)
+	}
+
+	// the snapshot is optional
+	earner := &distribution.EarnerLine{}
+	err := json.Unmarshal([]byte(`{"earner":"0xd37f737629e0ddad7fc8adc7247d2e79c0296c35","token":"0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9","cumulative_amount":"1"}`), earner)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xd37f737629e0ddad7fc8adc7247d2e79c0296c35", earner.Earner)
+	assert.Equal(t, "1", earner.CumulativeAmount)
+}
+
+func TestEarnerLineVerifyChecksum(t *testing.T) {
+	token := "0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9"
+	checksummed := common.HexToAddress("0xd37f737629e0ddad7fc8adc7247d2e79c0296c35").Hex()
+	// flip the case of the last letter to break the checksum
+	last := strings.LastIndexAny(checksummed, "abcdefABCDEF")
+	corrupted := checksummed[:last] + string(checksummed[last]^0x20) + checksummed[last+1:]
+
+	cases := map[string]bool{
+		checksummed:                             true,
+		strings.ToLower(checksummed):            true,
+		"0x" + strings.ToUpper(checksummed[2:]): true,
+		corrupted:                               false,
+	}
+	for earnerAddress, valid := range cases {
+		line := `{"earner":"` + earnerAddress + `","token":"` + token + `","snapshot":1716681600000,"cumulative_amount":"1"}`
+
+		earner := &distribution.EarnerLine{VerifyChecksum: true}
+		err := json.Unmarshal([]byte(line), earner)
+		if valid {
+			assert.NoError(t, err, earnerAddress)
+			assert.Equal(t, earnerAddress, earner.Earner)
+		} else {
+			assert.ErrorIs(t, err, distribution.ErrInvalidChecksum, earnerAddress)
+		}
+
+		// without the option the checksum is not verified
+		err = json.Unmarshal([]byte(line), &distribution.EarnerLine{})
+		assert.NoError(t, err, earnerAddress)
+	}
+}
+
+func TestEarnerLineExtraFields(t *testing.T) {
+	line := `{"earner":"0xd37f737629e0ddad7fc8adc7247d2e79c0296c35","token":"0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9","snapshot":1716681600000,"cumulative_amount":"2.690822691e+27","reward_type":"operator_directed","program_id":42,"nested":{"a":[1,2]}}`
+
+	earner := &distribution.EarnerLine{}
+	err := json.Unmarshal([]byte(line), earner)
+	assert.NoError(t, err)
+	assert.Equal(t, "0xd37f737629e0ddad7fc8adc7247d2e79c0296c35", earner.Earner)
+	assert.Equal(t, "0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9", earner.Token)
+	assert.Equal(t, uint64(1716681600000), earner.Snapshot)
+	assert.Equal(t, "2.690822691e+27", earner.CumulativeAmount)
+
+	extended := &distribution.ExtendedEarnerLine{}
+	err = json.Unmarshal([]byte(line), extended)
+	assert.NoError(t, err)
+	assert.Equal(t, *earner, extended.EarnerLine)
+	assert.Equal(t, "operator_directed", extended.RewardType)
+	assert.Equal(t, "42", extended.ProgramId)
+
+	// the optional fields may be missing, and the core fields are still validated
+	extended = &distribution.ExtendedEarnerLine{}
+	err = json.Unmarshal([]byte(`{"earner":"0x01","token":"0x02","snapshot":1716681600000,"cumulative_amount":"1","program_id":null}`), extended)
+	assert.NoError(t, err)
+	assert.Empty(t, extended.RewardType)
+	assert.Empty(t, extended.ProgramId)
+	err = json.Unmarshal([]byte(`{"earner":"0x01","token":"0x02","snapshot":1716681600,"reward_type":"x"}`), extended)
+	assert.ErrorIs(t, err, distribution.ErrInvalidSnapshot)
+
+	d := distribution.NewDistribution()
+	err = d.LoadLinesFromReader(strings.NewReader(line))
+	assert.NoError(t, err)
+	amount, found := d.Get(common.HexToAddress(earner.Earner), common.HexToAddress(earner.Token))
+	assert.True(t, found)
+	assert.Equal(t, "2690822691000000000000000000", amount.String())
+}
+
+func TestCumulativeAmountBigInt(t *testing.T) {
+	valid := map[string]string{
+		"2690822690822645700000000000": "2690822690822645700000000000",
+		"2.690822691e+27":              "2690822691000000000000000000",
+		"1.083011266e+19":              "10830112660000000000",
+		"1E3":                          "1000",
+		"0":                            "0",
+		"0x1bc16d674ec80000":           "2000000000000000000",
+		"0X1BC16D674EC80000":           "2000000000000000000",
+		"0x0":                          "0",
+	}
+	for input, expected := range valid {
+		line := &distribution.EarnerLine{CumulativeAmount: input}
+		amount, err := line.CumulativeAmountBigInt()
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, amount.String(), input)
+	}
+
+	for _, input := range []string{"1.5", "2.6908226915e+9", "", "abc", "0x", "0xfg", "0x-1", "0x1_0", "0x1p4", "0x1.8"} {
+		line := &distribution.EarnerLine{CumulativeAmount: input}
+		_, err := line.CumulativeAmountBigInt()
+		assert.Error(t, err, input)
+	}
+
+	_, err := (&distribution.EarnerLine{CumulativeAmount: "0xfg"}).CumulativeAmountBigInt()
+	assert.ErrorContains(t, err, "malformed hex amount: 0xfg")
+}
+
+func TestAmountRoundTrip(t *testing.T) {
+	// scientific notation with trailing zeros parses to the same integer as its plain form
+	for scientific, plain := range map[string]string{
+		"2.690822691e+27":        "2690822691000000000000000000",
+		"7.1428571428570649e+27": "7142857142857064900000000000",
+		"8.5714285714285409e+35": "857142857142854090000000000000000000",
+	} {
+		fromScientific, err := (&distribution.EarnerLine{CumulativeAmount: scientific}).CumulativeAmountBigInt()
+		assert.NoError(t, err)
+		fromPlain, err := (&distribution.EarnerLine{CumulativeAmount: plain}).CumulativeAmountBigInt()
+		assert.NoError(t, err)
+		assert.Equal(t, fromPlain, fromScientific, scientific)
+	}
+
+	amounts := []string{
+		"2690822690822645700000000000",
+		"7142857142857064900000000000",
+		"857142857142854090000000000000000000",
+		"6102895758009265",
+	}
+	d := distribution.NewDistribution()
+	for i, input := range amounts {
+		amount, err := (&distribution.EarnerLine{CumulativeAmount: input}).CumulativeAmountBigInt()
+		assert.NoError(t, err)
+		err = d.Set(tests.TestAddresses[i], tests.TestTokens[0], amount)
+		assert.NoError(t, err)
+	}
+
+	// earner lines, JSON and CSV all emit the exact digits
+	lines := d.ToEarnerLines(1716681600000)
+	assert.Len(t, lines, len(amounts))
+	for i, line := range lines {
+		assert.Equal(t, amounts[i], line.CumulativeAmount)
+		amount, err := line.CumulativeAmountBigInt()
+		assert.NoError(t, err)
+		expected, _ := d.Get(tests.TestAddresses[i], tests.TestTokens[0])
+		assert.Equal(t, expected, amount)
+	}
+
+	encoded, err := json.Marshal(d)
+	assert.NoError(t, err)
+	decoded := distribution.NewDistribution()
+	err = json.Unmarshal(encoded, decoded)
+	assert.NoError(t, err)
+	assert.True(t, d.Equal(decoded))
+
+	var csv strings.Builder
+	err = d.WriteCSV(&csv)
+	assert.NoError(t, err)
+	for _, output := range []string{string(encoded), csv.String()} {
+		assert.NotContains(t, output, "e+")
+		for _, amount := range amounts {
+			assert.Contains(t, output, amount)
+		}
+	}
 }
 
 func getFullTestEarnerLines() string {
@@ -0,0 +1,98 @@
+package distribution
+
+import (
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// FilterTokens returns a new distribution holding only the allowed tokens, in the same order.
+// Earners left without any tokens are dropped so they do not produce empty token trees.
+// The returned amounts are copies that do not alias the distribution.
+func (d *Distribution) FilterTokens(allowed map[gethcommon.Address]bool) *Distribution {
+	filtered := d.newEmpty()
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		tokens := orderedmap.New[gethcommon.Address, *BigInt](accountPair.Value.Len())
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			if !allowed[tokenPair.Key] {
+				continue
+			}
+			tokens.Set(tokenPair.Key, tokenPair.Value.clone())
+		}
+		if tokens.Len() > 0 {
+			filtered.data.Set(accountPair.Key, tokens)
+		}
+	}
+	return filtered
+}
+
+// FilterEarners returns a new distribution holding only the earners for which keep returns true,
+// with all of their tokens and in the same order. The returned amounts are copies that do not alias
+// the distribution, and the result is not merklized.
+func (d *Distribution) FilterEarners(keep func(gethcommon.Address) bool) *Distribution {
+	filtered := d.newEmpty()
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		if !keep(accountPair.Key) {
+			continue
+		}
+		tokens := orderedmap.New[gethcommon.Address, *BigInt](accountPair.Value.Len())
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			tokens.Set(tokenPair.Key, tokenPair.Value.clone())
+		}
+		filtered.data.Set(accountPair.Key, tokens)
+	}
+	return filtered
+}
+
+// PruneBelow returns a new distribution without the pairs of token whose amount is below minAmount,
+// dropping earners left without any tokens, so dust earners that would spend more on gas than they
+// claim are excluded. Other tokens are kept whatever their amount. The result has a different root
+// whenever a pair was removed, so pruning is opt-in and has to be applied the same way by everyone
+// computing the root. The returned amounts are copies that do not alias the distribution.
+func (d *Distribution) PruneBelow(token gethcommon.Address, minAmount *big.Int) *Distribution {
+	if minAmount == nil {
+		minAmount = new(big.Int)
+	}
+	pruned := d.newEmpty()
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		tokens := orderedmap.New[gethcommon.Address, *BigInt](accountPair.Value.Len())
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			if tokenPair.Key == token && amountOrZero(tokenPair.Value).Cmp(minAmount) < 0 {
+				continue
+			}
+			tokens.Set(tokenPair.Key, tokenPair.Value.clone())
+		}
+		if tokens.Len() > 0 {
+			pruned.data.Set(accountPair.Key, tokens)
+		}
+	}
+	return pruned
+}
+
+// PruneZero removes every earner/token pair with a zero amount, along with earners left without
+// any tokens. This changes the root if any pair was removed, see OmitZeroAmounts to skip them while loading.
+func (d *Distribution) PruneZero() {
+	pruned := false
+	for accountPair := d.data.Oldest(); accountPair != nil; {
+		tokens := accountPair.Value
+		for tokenPair := tokens.Oldest(); tokenPair != nil; {
+			next := tokenPair.Next()
+			if amountOrZero(tokenPair.Value).Sign() == 0 {
+				tokens.Delete(tokenPair.Key)
+				pruned = true
+			}
+			tokenPair = next
+		}
+
+		next := accountPair.Next()
+		if tokens.Len() == 0 {
+			d.data.Delete(accountPair.Key)
+			pruned = true
+		}
+		accountPair = next
+	}
+	if pruned {
+		d.invalidate()
+	}
+}
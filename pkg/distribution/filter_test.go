@@ -0,0 +1,199 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterTokens(t *testing.T) {
+	d := GetTestDistribution()
+
+	allowed := make(map[gethcommon.Address]bool)
+	for _, token := range tests.TestTokens[1:] {
+		allowed[token] = true
+	}
+	filtered := d.FilterTokens(allowed)
+
+	// the last earner only held the filtered token
+	assert.Equal(t, tests.TestAddresses[:4], filtered.Earners())
+	for i, earner := range filtered.Earners() {
+		assert.Equal(t, tests.TestTokens[1:5-i], filtered.TokensForEarner(earner))
+	}
+
+	// the source distribution is left untouched
+	_, found := d.Get(tests.TestAddresses[4], tests.TestTokens[0])
+	assert.True(t, found)
+
+	expected := distribution.NewDistribution()
+	for i := 0; i < 4; i++ {
+		for j := 1; j < 5-i; j++ {
+			err := expected.Set(tests.TestAddresses[i], tests.TestTokens[j], big.NewInt(int64(j+i+1)))
+			assert.NoError(t, err)
+		}
+	}
+	expectedRoot, err := expected.Root()
+	assert.NoError(t, err)
+	filteredRoot, err := filtered.Root()
+	assert.NoError(t, err)
+	originalRoot, err := d.Root()
+	assert.NoError(t, err)
+
+	assert.Equal(t, expectedRoot, filteredRoot)
+	assert.NotEqual(t, originalRoot, filteredRoot)
+}
+
+func TestFilterTokensCopiesAmounts(t *testing.T) {
+	d := GetTestDistribution()
+	filtered := d.FilterTokens(map[gethcommon.Address]bool{tests.TestTokens[0]: true})
+	assert.Equal(t, tests.TestAddresses[:5], filtered.Earners())
+
+	amount, _ := filtered.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	amount.SetInt64(100)
+
+	original, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(1), original)
+
+	assert.Empty(t, d.FilterTokens(nil).Earners())
+}
+
+func TestFilterEarners(t *testing.T) {
+	d := GetTestDistribution()
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	kept := map[gethcommon.Address]bool{}
+	for i := 0; i < len(tests.TestAddresses); i += 2 {
+		kept[tests.TestAddresses[i]] = true
+	}
+	filtered := d.FilterEarners(func(earner gethcommon.Address) bool { return kept[earner] })
+
+	assert.Equal(t, []gethcommon.Address{tests.TestAddresses[0], tests.TestAddresses[2], tests.TestAddresses[4]}, filtered.Earners())
+	for _, earner := range filtered.Earners() {
+		assert.Equal(t, d.TokensForEarner(earner), filtered.TokensForEarner(earner))
+		for _, token := range d.TokensForEarner(earner) {
+			expected, _ := d.Get(earner, token)
+			amount, found := filtered.Get(earner, token)
+			assert.True(t, found)
+			assert.Equal(t, expected, amount)
+		}
+	}
+
+	// the result starts unmerklized and does not alias the source
+	_, _, err = filtered.AccountProof(tests.TestAddresses[0])
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+	amount, _ := filtered.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	amount.SetInt64(100)
+	original, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(1), original)
+
+	assert.Empty(t, d.FilterEarners(func(gethcommon.Address) bool { return false }).Earners())
+}
+
+func TestPruneBelow(t *testing.T) {
+	d := GetTestDistribution()
+	totals := d.TokenTotals()
+
+	// earner i has i+1 of the first token
+	pruned := d.PruneBelow(tests.TestTokens[0], big.NewInt(3))
+	for i, earner := range tests.TestAddresses {
+		expected := d.TokensForEarner(earner)
+		if i < 2 {
+			expected = expected[1:]
+		}
+		assert.Equal(t, expected, pruned.TokensForEarner(earner))
+	}
+	prunedTotals := pruned.TokenTotals()
+	assert.Equal(t, new(big.Int).Sub(totals[tests.TestTokens[0]], big.NewInt(1+2)), prunedTotals[tests.TestTokens[0]])
+	for _, token := range tests.TestTokens[1:] {
+		assert.Equal(t, totals[token], prunedTotals[token])
+	}
+
+	// the last earner only has the first token, so it is dropped
+	pruned = d.PruneBelow(tests.TestTokens[0], big.NewInt(100))
+	assert.Equal(t, tests.TestAddresses[:4], pruned.Earners())
+	_, found := pruned.TokenTotals()[tests.TestTokens[0]]
+	assert.False(t, found)
+
+	// the threshold is exclusive and the source is untouched
+	assert.True(t, d.PruneBelow(tests.TestTokens[0], big.NewInt(1)).Equal(d))
+	assert.True(t, d.Equal(GetTestDistribution()))
+}
+
+// getTestDistributionWithZeros returns GetTestDistribution with a zero amount for each token
+// missing from an earner, and an extra earner holding only zero amounts
+func getTestDistributionWithZeros(t *testing.T) *distribution.Distribution {
+	d := distribution.NewDistribution()
+	for i, earner := range tests.TestAddresses {
+		for j, token := range tests.TestTokens {
+			amount := big.NewInt(int64(j + i + 1))
+			if j >= len(tests.TestTokens)-i {
+				amount = new(big.Int)
+			}
+			err := d.Set(earner, token, amount)
+			assert.NoError(t, err)
+		}
+	}
+	err := d.Set(gethcommon.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"), tests.TestTokens[0], big.NewInt(0))
+	assert.NoError(t, err)
+	err = d.Set(gethcommon.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"), tests.TestTokens[1], nil)
+	assert.NoError(t, err)
+	return d
+}
+
+func TestPruneZero(t *testing.T) {
+	d := getTestDistributionWithZeros(t)
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+	totals := d.TokenTotals()
+	depth, err := d.AccountTreeDepth()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, depth)
+	depth, err = d.TokenTreeDepth(tests.TestAddresses[4])
+	assert.NoError(t, err)
+	assert.Equal(t, 3, depth)
+
+	d.PruneZero()
+	assert.Equal(t, totals, d.TokenTotals())
+	assert.True(t, d.Equal(GetTestDistribution()))
+
+	// pruning clears the cached trees, which are now smaller
+	_, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+	depth, err = d.TokenTreeDepth(tests.TestAddresses[4])
+	assert.NoError(t, err)
+	assert.Equal(t, 0, depth)
+
+	// pruning again is a no-op and keeps the trees
+	d.PruneZero()
+	_, found = d.GetAccountIndex(tests.TestAddresses[0])
+	assert.True(t, found)
+}
+
+func TestOmitZeroAmounts(t *testing.T) {
+	lines := []*distribution.EarnerLine{
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "1"},
+		{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[1].Hex(), CumulativeAmount: "0"},
+		{Earner: tests.TestAddresses[1].Hex(), Token: tests.TestTokens[0].Hex(), CumulativeAmount: "0e+18"},
+	}
+
+	d := distribution.NewDistribution()
+	err := d.LoadLines(lines)
+	assert.NoError(t, err)
+	assert.Len(t, d.Earners(), 2)
+
+	d = distribution.NewDistribution()
+	d.OmitZeroAmounts = true
+	err = d.LoadLines(lines)
+	assert.NoError(t, err)
+	assert.Equal(t, []gethcommon.Address{tests.TestAddresses[0]}, d.Earners())
+	assert.Equal(t, []gethcommon.Address{tests.TestTokens[0]}, d.TokensForEarner(tests.TestAddresses[0]))
+}
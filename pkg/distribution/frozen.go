@@ -0,0 +1,150 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/wealdtech/go-merkletree/v2"
+)
+
+// ReadOnlyDistribution is a merklized distribution that can no longer change, obtained with Freeze.
+//
+// All of its methods are safe for concurrent use by any number of goroutines without locking: the view owns
+// a private copy of the amounts that nothing else can reach, every amount is parsed and every tree and index
+// is built before Freeze returns, and reads never write to them afterwards. The returned amounts, proofs and
+// roots are fresh copies, so callers may modify them without affecting other readers.
+// Set returns ErrFrozen; a distribution that needs to change is modified and frozen again.
+type ReadOnlyDistribution struct {
+	d *Distribution
+}
+
+// Freeze merklizes the distribution and returns a read-only view of it, see ReadOnlyDistribution.
+// The view does not alias the distribution, which can still be mutated and merklized afterwards without
+// affecting the view, and the trees are shared rather than rebuilt as they are never modified once built.
+func (d *Distribution) Freeze() (*ReadOnlyDistribution, error) {
+	accountTree, tokenTrees, err := d.Merklize()
+	if err != nil {
+		return nil, err
+	}
+
+	frozen := d.Clone()
+	for accountPair := frozen.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			// parse amounts kept as strings once now rather than on every read
+			tokenPair.Value.parse()
+		}
+	}
+
+	frozen.accountTree = accountTree
+	frozen.tokenTrees = make(map[gethcommon.Address]*merkletree.MerkleTree, len(tokenTrees))
+	for earner, tokenTree := range tokenTrees {
+		frozen.tokenTrees[earner] = tokenTree
+	}
+	frozen.accountIndices = make(map[gethcommon.Address]uint64, len(d.accountIndices))
+	for earner, index := range d.accountIndices {
+		frozen.accountIndices[earner] = index
+	}
+	frozen.tokenIndices = make(map[gethcommon.Address]map[gethcommon.Address]uint64, len(d.tokenIndices))
+	for earner, indices := range d.tokenIndices {
+		frozen.tokenIndices[earner] = make(map[gethcommon.Address]uint64, len(indices))
+		for token, index := range indices {
+			frozen.tokenIndices[earner][token] = index
+		}
+	}
+	return &ReadOnlyDistribution{d: frozen}, nil
+}
+
+// Set always returns ErrFrozen, the amounts of a frozen distribution cannot change.
+func (r *ReadOnlyDistribution) Set(address, token gethcommon.Address, amount *big.Int) error {
+	return fmt.Errorf("%w - earner: %s, token: %s", ErrFrozen, address.Hex(), token.Hex())
+}
+
+// Get returns a copy of the amount of an earner/token pair and whether it is in the distribution.
+func (r *ReadOnlyDistribution) Get(address, token gethcommon.Address) (*big.Int, bool) {
+	return r.d.GetCopy(address, token)
+}
+
+// Earners returns the earners in the order of GetAccountIndex.
+func (r *ReadOnlyDistribution) Earners() []gethcommon.Address {
+	return r.d.Earners()
+}
+
+// TokensForEarner returns the tokens of an earner in the order of GetTokenIndex.
+func (r *ReadOnlyDistribution) TokensForEarner(earner gethcommon.Address) []gethcommon.Address {
+	return r.d.TokensForEarner(earner)
+}
+
+// Len returns the number of earner/token pairs.
+func (r *ReadOnlyDistribution) Len() int {
+	return r.d.Len()
+}
+
+// EarnerCount returns the number of earners.
+func (r *ReadOnlyDistribution) EarnerCount() int {
+	return r.d.EarnerCount()
+}
+
+// Snapshot returns the snapshot of the distribution when it was frozen.
+func (r *ReadOnlyDistribution) Snapshot() uint64 {
+	return r.d.Snapshot
+}
+
+// CalculationEndTimestamp returns the calculation end timestamp of the distribution when it was frozen.
+func (r *ReadOnlyDistribution) CalculationEndTimestamp() uint64 {
+	return r.d.CalculationEndTimestamp
+}
+
+// Root returns the root of the account tree.
+func (r *ReadOnlyDistribution) Root() []byte {
+	return copyBytes(r.d.accountTree.Root())
+}
+
+// EarnerTokenRoot returns the root of an earner's token tree, the one committed to by its account leaf,
+// and whether the earner is in the distribution.
+func (r *ReadOnlyDistribution) EarnerTokenRoot(earner gethcommon.Address) ([]byte, bool) {
+	tokenTree, found := r.d.tokenTrees[earner]
+	if !found {
+		return nil, false
+	}
+	return copyBytes(tokenTree.Root()), true
+}
+
+// GetAccountIndex returns the index of an earner in the account tree.
+func (r *ReadOnlyDistribution) GetAccountIndex(earner gethcommon.Address) (uint64, bool) {
+	return r.d.GetAccountIndex(earner)
+}
+
+// GetTokenIndex returns the index of a token in an earner's token tree.
+func (r *ReadOnlyDistribution) GetTokenIndex(earner, token gethcommon.Address) (uint64, bool) {
+	return r.d.GetTokenIndex(earner, token)
+}
+
+// AccountProof behaves like Distribution.AccountProof.
+func (r *ReadOnlyDistribution) AccountProof(earner gethcommon.Address) ([][]byte, uint64, error) {
+	proof, earnerIndex, err := r.d.AccountProof(earner)
+	return copyHashes(proof), earnerIndex, err
+}
+
+// TokenProof behaves like Distribution.TokenProof.
+func (r *ReadOnlyDistribution) TokenProof(earner, token gethcommon.Address) ([][]byte, uint64, error) {
+	proof, tokenIndex, err := r.d.TokenProof(earner, token)
+	return copyHashes(proof), tokenIndex, err
+}
+
+// copyBytes returns a copy of b that does not alias the nodes of a tree
+func copyBytes(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
+
+// copyHashes returns a copy of the hashes of a proof that does not alias the nodes of a tree
+func copyHashes(hashes [][]byte) [][]byte {
+	if hashes == nil {
+		return nil
+	}
+	copied := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		copied[i] = copyBytes(hash)
+	}
+	return copied
+}
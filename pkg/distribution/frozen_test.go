@@ -0,0 +1,90 @@
+package distribution_test
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/claimgen"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreeze(t *testing.T) {
+	d := GetTestDistribution()
+	frozen, err := d.Freeze()
+	assert.NoError(t, err)
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, root, frozen.Root())
+	assert.Equal(t, d.Earners(), frozen.Earners())
+	assert.Equal(t, d.Len(), frozen.Len())
+
+	err = frozen.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrFrozen)
+
+	// mutating the distribution or the returned values leaves the view as it was
+	amount, found := frozen.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.True(t, found)
+	expected := new(big.Int).Set(amount)
+	amount.SetInt64(0)
+	proof, _, err := frozen.AccountProof(tests.TestAddresses[0])
+	assert.NoError(t, err)
+	proof[0][0] ^= 0xff
+	assert.NoError(t, d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1)))
+
+	amount, _ = frozen.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, expected, amount)
+	assert.Equal(t, root, frozen.Root())
+	assertFrozenClaimsVerify(t, frozen, root)
+
+	_, err = distribution.NewDistribution().Freeze()
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+}
+
+func assertFrozenClaimsVerify(t *testing.T, frozen *distribution.ReadOnlyDistribution, root []byte) {
+	for _, earner := range frozen.Earners() {
+		claim, err := claimgen.GenerateFrozenClaimProof(frozen, earner, frozen.TokensForEarner(earner), 0)
+		assert.NoError(t, err)
+		valid, err := claimgen.VerifyClaim(root, claim)
+		assert.NoError(t, err)
+		assert.True(t, valid, "earner %s", earner.Hex())
+	}
+}
+
+// TestFreezeConcurrentProofs is meant to be run with -race, which reports any write to the frozen
+// distribution while the proofs are generated.
+func TestFreezeConcurrentProofs(t *testing.T) {
+	d := distribution.NewDistribution(distribution.WithStringAmounts())
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	frozen, err := d.Freeze()
+	assert.NoError(t, err)
+	root := frozen.Root()
+	earners := frozen.Earners()
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 16; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			// the workers overlap, so every earner is proven by several of them at once
+			for i := worker % 4; i < len(earners); i += 4 {
+				earner := earners[i]
+				tokens := frozen.TokensForEarner(earner)
+				claim, err := claimgen.GenerateFrozenClaimProof(frozen, earner, tokens, 0)
+				assert.NoError(t, err)
+				valid, err := claimgen.VerifyClaim(root, claim)
+				assert.NoError(t, err)
+				assert.True(t, valid, "earner %s", earner.Hex())
+
+				amount, found := frozen.Get(earner, tokens[0])
+				assert.True(t, found)
+				assert.Equal(t, claim.TokenLeaves[0].CumulativeEarnings, amount)
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
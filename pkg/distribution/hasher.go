@@ -0,0 +1,80 @@
+package distribution
+
+import (
+	"bytes"
+
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+// Hasher hashes the leaves and branch nodes of the merkle trees. Branch nodes are hashed
+// from the concatenation of their left and right children.
+type Hasher interface {
+	Hash(data []byte) []byte
+}
+
+// Option configures a Distribution created by NewDistribution.
+type Option func(*Distribution)
+
+// WithHasher sets the hash function used to build the merkle trees instead of keccak256.
+// The leaf encoding is unaffected, but the roots will no longer match the RewardsCoordinator.
+func WithHasher(hasher Hasher) Option {
+	return func(d *Distribution) {
+		if hasher != nil {
+			d.hashType = &hasherHashType{hasher: hasher}
+		}
+	}
+}
+
+// HashLeaf returns the hash of a leaf in the trees, keccak256 of the encoded leaf as returned by
+// EncodeTokenLeaf or EncodeAccountLeaf. Leaves added to pad a tree to a power of two are all zero
+// and are used as they are rather than hashed.
+func HashLeaf(data []byte) []byte {
+	return keccak256.New().Hash(data)
+}
+
+// HashNodes returns the hash of a branch node, keccak256 of the left child followed by the right child.
+// The children are not sorted: a node at an even index is always on the left, so a verifier must use the
+// bits of the leaf index to place each sibling, as the RewardsCoordinator does.
+func HashNodes(left, right []byte) []byte {
+	return keccak256.New().Hash(left, right)
+}
+
+// treeHashType returns the hash type used to build the merkle trees, keccak256 by default
+func (d *Distribution) treeHashType() merkletree.HashType {
+	if d.hashType == nil {
+		return keccak256.New()
+	}
+	return d.hashType
+}
+
+// hashTypeProbe is hashed by sameHashType to tell hash types apart
+var hashTypeProbe = []byte("eigenlayer-rewards-proofs")
+
+// sameHashType reports whether two hash types build the same trees. WithHasher wraps its Hasher on every
+// call, so they are compared by their output rather than by identity, and a custom keccak256 Hasher is the
+// same as the default.
+func sameHashType(a, b merkletree.HashType) bool {
+	return a.HashLength() == b.HashLength() && bytes.Equal(a.Hash(hashTypeProbe), b.Hash(hashTypeProbe))
+}
+
+// hasherHashType adapts a Hasher to the hash type used by the merkle tree library
+type hasherHashType struct {
+	hasher Hasher
+}
+
+func (h *hasherHashType) Hash(data ...[]byte) []byte {
+	var input []byte
+	for _, d := range data {
+		input = append(input, d...)
+	}
+	return h.hasher.Hash(input)
+}
+
+func (h *hasherHashType) HashName() string {
+	return "custom"
+}
+
+func (h *hasherHashType) HashLength() int {
+	return len(h.hasher.Hash(nil))
+}
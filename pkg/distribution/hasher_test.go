@@ -0,0 +1,103 @@
+package distribution_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDistributionRoot is the account root of GetTestDistribution built with keccak256
+const testDistributionRoot = "6cbbc579e43e27391fff6c2fe7c637dcc7ea7efe7b57ee42016b3c23c601415d"
+
+type keccakHasher struct{}
+
+func (keccakHasher) Hash(data []byte) []byte {
+	return crypto.Keccak256(data)
+}
+
+type sha256Hasher struct{}
+
+func (sha256Hasher) Hash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// getTestDistributionWithOptions builds the same entries as GetTestDistribution with the given options
+func getTestDistributionWithOptions(opts ...distribution.Option) *distribution.Distribution {
+	d := distribution.NewDistribution(opts...)
+	for i := 0; i < len(tests.TestAddresses); i++ {
+		for j := 0; j < len(tests.TestTokens)-i; j++ {
+			d.Set(tests.TestAddresses[i], tests.TestTokens[j], big.NewInt(int64(j+i+1)))
+		}
+	}
+	return d
+}
+
+func TestDefaultHasherRoot(t *testing.T) {
+	root, err := GetTestDistribution().Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+
+	root, err = getTestDistributionWithOptions(distribution.WithHasher(keccakHasher{})).Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+}
+
+func TestCustomHasher(t *testing.T) {
+	d := getTestDistributionWithOptions(distribution.WithHasher(sha256Hasher{}))
+
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+	assert.NotEqual(t, testDistributionRoot, hex.EncodeToString(accountTree.Root()))
+
+	// the leaf encoding is unchanged, only the hashing differs
+	tokenTree := tokenTrees[tests.TestAddresses[4]]
+	leaf := distribution.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(5))
+	assert.Equal(t, leaf, tokenTree.Data[0])
+	assert.Equal(t, sha256Hasher{}.Hash(leaf), tokenTree.Root())
+
+	// derived distributions keep the hasher
+	delta, err := d.Subtract(distribution.NewDistribution())
+	assert.NoError(t, err)
+	deltaRoot, err := delta.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, accountTree.Root(), deltaRoot)
+}
+
+func TestHashLeafAndNodes(t *testing.T) {
+	d := GetTestDistribution()
+	_, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	// the second earner has four tokens with amounts 2 to 5, so its token tree is full
+	hashes := make([][]byte, 0, 4)
+	for j := 0; j < 4; j++ {
+		hashes = append(hashes, distribution.HashLeaf(distribution.EncodeTokenLeaf(tests.TestTokens[j], big.NewInt(int64(j+2)))))
+	}
+	root := distribution.HashNodes(distribution.HashNodes(hashes[0], hashes[1]), distribution.HashNodes(hashes[2], hashes[3]))
+	assert.Equal(t, "acb9ba407768c011e5d1cf99725c803f8e925935a430b9c42e6e6ca5dcd9b736", hex.EncodeToString(root))
+	assert.Equal(t, tokenTrees[tests.TestAddresses[1]].Root(), root)
+
+	// siblings are not sorted
+	swapped := distribution.HashNodes(distribution.HashNodes(hashes[1], hashes[0]), distribution.HashNodes(hashes[2], hashes[3]))
+	assert.NotEqual(t, root, swapped)
+
+	// the first earner has five tokens, padded to eight with zero leaves that are not hashed
+	hashes = hashes[:0]
+	for j := 0; j < 5; j++ {
+		hashes = append(hashes, distribution.HashLeaf(distribution.EncodeTokenLeaf(tests.TestTokens[j], big.NewInt(int64(j+1)))))
+	}
+	zero := make([]byte, 32)
+	root = distribution.HashNodes(
+		distribution.HashNodes(distribution.HashNodes(hashes[0], hashes[1]), distribution.HashNodes(hashes[2], hashes[3])),
+		distribution.HashNodes(distribution.HashNodes(hashes[4], zero), distribution.HashNodes(zero, zero)),
+	)
+	assert.Equal(t, "4acae56afcefa7a8cad4141f508bf21f0ba7e1604956f70b6ce1f7e16dbdf8cf", hex.EncodeToString(root))
+	assert.Equal(t, tokenTrees[tests.TestAddresses[0]].Root(), root)
+}
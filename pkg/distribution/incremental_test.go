@@ -0,0 +1,182 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func assertIncrementalRoot(t *testing.T, previous, d *distribution.Distribution) {
+	expected, err := d.Clone().Root()
+	assert.NoError(t, err)
+
+	accountTree, tokenTrees, err := d.MerklizeIncremental(previous)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, accountTree.Root())
+	assert.Len(t, tokenTrees, d.EarnerCount())
+
+	// the proofs are read from the updated trees
+	for _, earner := range d.Earners() {
+		proof, earnerIndex, err := d.AccountProof(earner)
+		assert.NoError(t, err)
+		earnerLeaf := distribution.EncodeAccountLeaf(earner, tokenTrees[earner].Root())
+		verified, err := merkletree.VerifyProofUsing(earnerLeaf, false, &merkletree.Proof{Hashes: proof, Index: earnerIndex}, [][]byte{expected}, keccak256.New())
+		assert.NoError(t, err)
+		assert.True(t, verified, "earner %s", earner.Hex())
+	}
+}
+
+func TestMerklizeIncremental(t *testing.T) {
+	previous := distribution.NewDistribution()
+	err := previous.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	_, previousTokenTrees, err := previous.Merklize()
+	assert.NoError(t, err)
+	previousRoot, err := previous.Root()
+	assert.NoError(t, err)
+
+	earners := previous.Earners()
+	d := previous.Clone()
+	changed := earners[len(earners)/3]
+	token := d.TokensForEarner(changed)[0]
+	amount, _ := d.Get(changed, token)
+	assert.NoError(t, d.Set(changed, token, new(big.Int).Add(amount, big.NewInt(1))))
+	assertIncrementalRoot(t, previous, d)
+
+	// only the token tree of the changed earner is rebuilt
+	_, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+	for _, earner := range earners {
+		if earner == changed {
+			assert.NotSame(t, previousTokenTrees[earner], tokenTrees[earner])
+		} else {
+			assert.Same(t, previousTokenTrees[earner], tokenTrees[earner], "earner %s", earner.Hex())
+		}
+	}
+
+	// the previous trees are left as they were
+	root, err := previous.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, previousRoot, root)
+
+	// neighbouring and distant leaves changed together
+	d = previous.Clone()
+	for _, earner := range []common.Address{earners[0], earners[1], earners[2], earners[len(earners)-1]} {
+		assert.NoError(t, d.Set(earner, d.TokensForEarner(earner)[0], big.NewInt(42)))
+	}
+	assertIncrementalRoot(t, previous, d)
+
+	// a new earner changes the shape of the account tree
+	d = previous.Clone()
+	assert.NoError(t, d.Set(common.HexToAddress("0xffffffffffffffffffffffffffffffffffffffff"), tests.TestTokens[0], big.NewInt(1)))
+	assertIncrementalRoot(t, previous, d)
+
+	// removing an earner too
+	d = previous.FilterEarners(func(earner common.Address) bool {
+		return earner != earners[5]
+	})
+	assertIncrementalRoot(t, previous, d)
+
+	// no change at all
+	assertIncrementalRoot(t, previous, previous.Clone())
+}
+
+func TestMerklizeIncrementalSingleEarner(t *testing.T) {
+	previous := distribution.NewDistribution()
+	assert.NoError(t, previous.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1)))
+	_, _, err := previous.Merklize()
+	assert.NoError(t, err)
+
+	d := previous.Clone()
+	assert.NoError(t, d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(2)))
+	assertIncrementalRoot(t, previous, d)
+}
+
+func TestMerklizeIncrementalShard(t *testing.T) {
+	full := distribution.NewDistribution()
+	err := full.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	shards, err := full.ShardByEarner(2)
+	assert.NoError(t, err)
+
+	// the shard keeps the full account tree with fewer earners
+	for _, shard := range shards {
+		d := shard.Clone()
+		earner := d.Earners()[0]
+		assert.NoError(t, d.Set(earner, d.TokensForEarner(earner)[0], big.NewInt(42)))
+		assertIncrementalRoot(t, shard, d)
+	}
+
+	// as many earners as the shard's account tree, most of them missing from the shard
+	d := full.Clone()
+	earner := d.Earners()[0]
+	assert.NoError(t, d.Set(earner, d.TokensForEarner(earner)[0], big.NewInt(42)))
+	assertIncrementalRoot(t, shards[1], d)
+}
+
+func TestMerklizeIncrementalErrors(t *testing.T) {
+	previous := GetTestDistribution()
+	d := previous.Clone()
+	_, _, err := d.MerklizeIncremental(previous)
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	_, _, err = previous.Merklize()
+	assert.NoError(t, err)
+	_, _, err = distribution.NewDistribution().MerklizeIncremental(previous)
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+
+	d.Arity = 4
+	_, _, err = d.MerklizeIncremental(previous)
+	assert.ErrorIs(t, err, distribution.ErrUnsupportedArity)
+
+	custom := getTestDistributionWithOptions(distribution.WithHasher(sha256Hasher{}))
+	_, _, err = custom.MerklizeIncremental(previous)
+	assert.ErrorIs(t, err, distribution.ErrTreesMismatch)
+}
+
+func TestMerklizeIncrementalHasher(t *testing.T) {
+	// each option wraps the hasher separately, the trees are still reused
+	previous := getTestDistributionWithOptions(distribution.WithHasher(sha256Hasher{}))
+	_, _, err := previous.Merklize()
+	assert.NoError(t, err)
+	d := getTestDistributionWithOptions(distribution.WithHasher(sha256Hasher{}))
+	d.Set(tests.TestAddresses[4], tests.TestTokens[0], big.NewInt(42))
+
+	accountTree, _, err := d.MerklizeIncremental(previous)
+	assert.NoError(t, err)
+	expected, err := d.ComputeRoot()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, accountTree.Root())
+}
+
+func BenchmarkMerklizeIncremental(b *testing.B) {
+	previous := getLargeTestDistribution(10000)
+	if _, _, err := previous.Merklize(); err != nil {
+		b.Fatal(err)
+	}
+	d := previous.Clone()
+	earner := common.BigToAddress(big.NewInt(5000))
+
+	b.Run("full", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			d.Set(earner, common.HexToAddress("0x01"), big.NewInt(int64(i)))
+			if _, _, err := d.Merklize(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("incremental", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			d.Set(earner, common.HexToAddress("0x01"), big.NewInt(int64(i)))
+			if _, _, err := d.MerklizeIncremental(previous); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,253 @@
+package distribution
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// ParseError is returned by the line loaders when a line cannot be decoded or loaded, so the failing line of a
+// large input can be found with errors.As. LineNumber is 1-based: the line of the input for LoadLinesFromReader,
+// the row for LoadFromIterator and the position in the slice as it was passed, before sorting, for LoadLines.
+// Field is the JSON name of the field that failed and RawValue its value as read, RawValue is the whole line
+// when it is not valid JSON or a field has the wrong type. Field is empty when the line as a whole fails, as
+// a duplicate does for example.
+// Err is the underlying error, which errors.Is matches against the sentinel errors.
+type ParseError struct {
+	LineNumber int
+	Field      string
+	RawValue   string
+	Err        error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("failed to parse line %d: %v", e.LineNumber, e.Err)
+	}
+	return fmt.Sprintf("failed to parse line %d: %v - field: %s, value: %s", e.LineNumber, e.Err, e.Field, e.RawValue)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError is an error about one field of a line, which the loaders turn into a ParseError
+type fieldError struct {
+	field    string
+	rawValue string
+	err      error
+}
+
+func (e *fieldError) Error() string {
+	return e.err.Error()
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.err
+}
+
+// newParseError wraps an error from decoding or loading a line, taking the field from a fieldError or a
+// json.UnmarshalTypeError. raw is the line as read, if any, and is kept for JSON errors.
+func newParseError(lineNumber int, raw string, err error) *ParseError {
+	parseErr := &ParseError{LineNumber: lineNumber, Err: err}
+	var fieldErr *fieldError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &fieldErr):
+		parseErr.Field = fieldErr.field
+		parseErr.RawValue = fieldErr.rawValue
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		parseErr.Field = typeErr.Field
+		parseErr.RawValue = raw
+	case errors.As(err, &syntaxErr):
+		parseErr.RawValue = raw
+	}
+	return parseErr
+}
+
+// DefaultMaxLineBytes is the maximum line size used by LoadLinesFromReader when
+// Distribution.MaxLineBytes is not set.
+const DefaultMaxLineBytes = bufio.MaxScanTokenSize
+
+// WithMaxLineBytes sets the maximum line size accepted by the streaming loaders, see Distribution.MaxLineBytes.
+func WithMaxLineBytes(maxLineBytes int) Option {
+	return func(d *Distribution) {
+		d.MaxLineBytes = maxLineBytes
+	}
+}
+
+// LoadLinesFromReader reads newline delimited JSON earner lines from r and sets them one at a time,
+// so memory usage does not depend on the size of the input. Blank lines are skipped, and a line longer
+// than MaxLineBytes is reported with ErrLineTooLong rather than truncated.
+//
+// Unlike LoadLines the lines are not sorted, they must already be in earner/token order.
+// Lines that cannot be decoded or loaded are reported with a ParseError.
+func (d *Distribution) LoadLinesFromReader(r io.Reader) error {
+	err := d.scanLines(r, func(lineNumber int, line *EarnerLine) error {
+		if err := d.loadLine(line); err != nil {
+			return newParseError(lineNumber, "", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.reportLoadComplete()
+	return nil
+}
+
+// LoadFromIterator sets the earner lines returned by next until it returns false, so rows can be
+// streamed from a database cursor without collecting them first. An error from next is returned as is,
+// while rows that cannot be loaded are reported with a ParseError holding the row number.
+//
+// Like LoadLinesFromReader the lines are not sorted, they must already be in earner/token order and
+// ErrAddressNotInOrder or ErrTokenNotInOrder is returned otherwise.
+func (d *Distribution) LoadFromIterator(next func() (*EarnerLine, bool, error)) error {
+	for row := 1; ; row++ {
+		line, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		if err := d.loadLine(line); err != nil {
+			return newParseError(row, "", err)
+		}
+	}
+	d.reportLoadComplete()
+	return nil
+}
+
+// scanLines calls fn with every earner line read from r that is not blank or whitespace only, along with its
+// 1-based line number.
+// Lines that cannot be decoded or are too long are reported with a ParseError.
+func (d *Distribution) scanLines(r io.Reader, fn func(lineNumber int, line *EarnerLine) error) error {
+	maxLineBytes := d.MaxLineBytes
+	if maxLineBytes <= 0 {
+		maxLineBytes = DefaultMaxLineBytes
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(maxLineBytes, bufio.MaxScanTokenSize)), maxLineBytes)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		raw := scanner.Bytes()
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		line := &EarnerLine{}
+		if err := json.Unmarshal(raw, line); err != nil {
+			return newParseError(lineNumber, string(raw), err)
+		}
+		if err := fn(lineNumber, line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return &ParseError{
+				LineNumber: lineNumber + 1,
+				Err:        fmt.Errorf("%w - line: %d, max bytes: %d", ErrLineTooLong, lineNumber+1, maxLineBytes),
+			}
+		}
+		return fmt.Errorf("failed to read line %d: %w", lineNumber+1, err)
+	}
+	return nil
+}
+
+// LoadLinesFromGzip behaves like LoadLinesFromReader for a gzip compressed stream of earner lines.
+func (d *Distribution) LoadLinesFromGzip(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	return d.LoadLinesFromReader(gz)
+}
+
+// LoadDistributionFromTar reads a tar archive holding one file of newline delimited earner lines per token.
+// The lines of every regular file are collected and loaded together with LoadLinesStrict, so the files may
+// be in any order but an earner/token pair may only appear once across the archive. Every error about a line
+// names its tar entry and holds a ParseError with the line number in that entry.
+func LoadDistributionFromTar(r io.Reader) (*Distribution, error) {
+	// tarLine is where a line was read from, so errors from loading all the lines together point at it
+	type tarLine struct {
+		name       string
+		lineNumber int
+	}
+
+	distro := NewDistribution()
+	lines := make([]*EarnerLine, 0)
+	origins := make([]tarLine, 0)
+	seen := make(map[gethcommon.Address]map[gethcommon.Address]tarLine)
+
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		err = distro.scanLines(archive, func(lineNumber int, line *EarnerLine) error {
+			// the snapshot is checked here, LoadLinesStrict would only know the position across the archive
+			if err := distro.checkLineSnapshot(line); err != nil {
+				return newParseError(lineNumber, "", err)
+			}
+
+			earner := gethcommon.HexToAddress(line.Earner)
+			token := gethcommon.HexToAddress(line.Token)
+			tokens, found := seen[earner]
+			if !found {
+				tokens = make(map[gethcommon.Address]tarLine)
+				seen[earner] = tokens
+			}
+			if prev, found := tokens[token]; found {
+				return newParseError(lineNumber, "", fmt.Errorf("%w - earner: %s, token: %s, first: %s line %d",
+					ErrDuplicateEntry, earner.Hex(), token.Hex(), prev.name, prev.lineNumber))
+			}
+			origin := tarLine{name: header.Name, lineNumber: lineNumber}
+			tokens[token] = origin
+
+			lines = append(lines, line)
+			origins = append(origins, origin)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tar entry %s: %w", header.Name, err)
+		}
+	}
+
+	if err := distro.LoadLinesStrict(lines); err != nil {
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) && parseErr.LineNumber >= 1 && parseErr.LineNumber <= len(origins) {
+			origin := origins[parseErr.LineNumber-1]
+			return nil, fmt.Errorf("failed to parse tar entry %s: %w", origin.name, &ParseError{
+				LineNumber: origin.lineNumber,
+				Field:      parseErr.Field,
+				RawValue:   parseErr.RawValue,
+				Err:        parseErr.Err,
+			})
+		}
+		return nil, err
+	}
+	return distro, nil
+}
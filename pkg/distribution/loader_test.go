@@ -0,0 +1,421 @@
+package distribution_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+// getSortedTestEarnerLines returns the test earner lines of snapshot 1716681600000 sorted by earner and
+// token, the few lines of other snapshots would be rejected by the streaming loaders.
+// Every line starts with the lowercase earner and token, so sorting the raw lines is enough.
+func getSortedTestEarnerLines() []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(getFullTestEarnerLines(), "\n") {
+		if strings.Contains(line, `"snapshot":1716681600000,`) {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func TestLoadLinesFromReader(t *testing.T) {
+	lines := getSortedTestEarnerLines()
+
+	// blank lines are skipped
+	input := strings.Join(lines, "\n\n")
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesFromReader(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	loaded := 0
+	for _, l := range lines {
+		if l == "" {
+			continue
+		}
+		line := &distribution.EarnerLine{}
+		err := json.Unmarshal([]byte(l), line)
+		assert.NoError(t, err)
+
+		expected, err := line.CumulativeAmountBigInt()
+		assert.NoError(t, err)
+
+		amount, found := distro.Get(common.HexToAddress(line.Earner), common.HexToAddress(line.Token))
+		assert.True(t, found)
+		assert.Equal(t, expected, amount)
+		loaded++
+	}
+	assert.Equal(t, 596, loaded)
+
+	// as are whitespace only lines, and CRLF line endings are accepted
+	crlf := distribution.NewDistribution()
+	err = crlf.LoadLinesFromReader(strings.NewReader(strings.Join(lines, "\r\n \t\r\n\r\n") + "\r\n"))
+	assert.NoError(t, err)
+	assert.True(t, distro.Equal(crlf))
+}
+
+func TestLoadLinesFromReaderMalformedLine(t *testing.T) {
+	lines := getSortedTestEarnerLines()[1:4]
+	lines = append(lines[:2], `{"earner":`, lines[2])
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesFromReader(strings.NewReader(strings.Join(lines, "\n")))
+	assert.ErrorContains(t, err, "line 3")
+}
+
+func TestLoadLinesFromReaderUnsorted(t *testing.T) {
+	sorted := getSortedTestEarnerLines()
+	// the last earner comes before the others
+	lines := []string{sorted[len(sorted)-1], sorted[len(sorted)/2], sorted[0]}
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesFromReader(strings.NewReader(strings.Join(lines, "\n")))
+	assert.ErrorIs(t, err, distribution.ErrAddressNotInOrder)
+	assert.ErrorContains(t, err, "line 2")
+}
+
+// sliceIterator returns an iterator over lines for LoadFromIterator
+func sliceIterator(lines []*distribution.EarnerLine) func() (*distribution.EarnerLine, bool, error) {
+	i := 0
+	return func() (*distribution.EarnerLine, bool, error) {
+		if i == len(lines) {
+			return nil, false, nil
+		}
+		i++
+		return lines[i-1], true, nil
+	}
+}
+
+func TestLoadFromIterator(t *testing.T) {
+	sorted := getSortedTestEarnerLines()
+	lines := parseTestEarnerLines(t, strings.Join(sorted, "\n"))
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadFromIterator(sliceIterator(lines))
+	assert.NoError(t, err)
+	assert.Equal(t, len(lines), distro.Len())
+
+	expected := distribution.NewDistribution()
+	err = expected.LoadLinesFromReader(strings.NewReader(strings.Join(sorted, "\n")))
+	assert.NoError(t, err)
+	assert.True(t, expected.Equal(distro))
+
+	// rows out of order are rejected like Set
+	lines[0], lines[len(lines)-1] = lines[len(lines)-1], lines[0]
+	err = distribution.NewDistribution().LoadFromIterator(sliceIterator(lines))
+	assert.ErrorIs(t, err, distribution.ErrAddressNotInOrder)
+	var parseErr *distribution.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.LineNumber)
+
+	iteratorErr := errors.New("cursor closed")
+	err = distribution.NewDistribution().LoadFromIterator(func() (*distribution.EarnerLine, bool, error) {
+		return nil, false, iteratorErr
+	})
+	assert.ErrorIs(t, err, iteratorErr)
+}
+
+func TestLoadLinesFromGzip(t *testing.T) {
+	input := strings.Join(getSortedTestEarnerLines(), "\n")
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(input))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+
+	distro := distribution.NewDistribution()
+	err = distro.LoadLinesFromGzip(&compressed)
+	assert.NoError(t, err)
+
+	plain := distribution.NewDistribution()
+	err = plain.LoadLinesFromReader(strings.NewReader(input))
+	assert.NoError(t, err)
+
+	loaded := 0
+	for _, earner := range distro.Earners() {
+		loaded += len(distro.TokensForEarner(earner))
+	}
+	assert.Equal(t, 596, loaded)
+	assert.True(t, plain.Equal(distro))
+}
+
+func TestLoadLinesFromGzipInvalid(t *testing.T) {
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesFromGzip(strings.NewReader(getFullTestEarnerLines()))
+	assert.ErrorIs(t, err, gzip.ErrHeader)
+	assert.ErrorContains(t, err, "gzip")
+
+	// a truncated stream fails while reading the lines
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write([]byte(strings.Join(getSortedTestEarnerLines(), "\n")))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+
+	err = distro.LoadLinesFromGzip(bytes.NewReader(compressed.Bytes()[:compressed.Len()/2]))
+	assert.Error(t, err)
+}
+
+func TestLoadLinesFromReaderMaxLineBytes(t *testing.T) {
+	lines := getSortedTestEarnerLines()[1:4]
+	// unknown fields are ignored, so padding makes a long but valid line
+	long := strings.TrimSuffix(lines[1], "}") + `,"padding":"` + strings.Repeat("0", distribution.DefaultMaxLineBytes) + `"}`
+	input := strings.Join([]string{lines[0], long, lines[2]}, "\n")
+
+	distro := distribution.NewDistribution()
+	err := distro.LoadLinesFromReader(strings.NewReader(input))
+	assert.ErrorIs(t, err, distribution.ErrLineTooLong)
+	assert.ErrorContains(t, err, "line: 2")
+
+	distro = distribution.NewDistribution(distribution.WithMaxLineBytes(2 * distribution.DefaultMaxLineBytes))
+	err = distro.LoadLinesFromReader(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, distro.Len())
+
+	// the limit applies to gzip streams too
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write([]byte(input))
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+	err = distribution.NewDistribution().LoadLinesFromGzip(&compressed)
+	assert.ErrorIs(t, err, distribution.ErrLineTooLong)
+}
+
+// getTestEarnerLinesTar returns a tar archive with a file of sorted test earner lines per token
+// for the given snapshot, in reverse token order so the files interleave when merged
+func getTestEarnerLinesTar(t *testing.T, snapshot uint64) *bytes.Buffer {
+	byToken := make(map[string][]string)
+	tokens := make([]string, 0)
+	for _, l := range getSortedTestEarnerLines() {
+		if l == "" {
+			continue
+		}
+		line := &distribution.EarnerLine{}
+		err := json.Unmarshal([]byte(l), line)
+		assert.NoError(t, err)
+		if line.Snapshot != snapshot {
+			continue
+		}
+		if _, found := byToken[line.Token]; !found {
+			tokens = append(tokens, line.Token)
+		}
+		byToken[line.Token] = append(byToken[line.Token], l)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(tokens)))
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	err := tw.WriteHeader(&tar.Header{Name: "tokens/", Typeflag: tar.TypeDir, Mode: 0o755})
+	assert.NoError(t, err)
+	for _, token := range tokens {
+		content := strings.Join(byToken[token], "\n") + "\n"
+		err := tw.WriteHeader(&tar.Header{Name: "tokens/" + token + ".json", Mode: 0o644, Size: int64(len(content))})
+		assert.NoError(t, err)
+		_, err = tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	return &archive
+}
+
+func TestLoadDistributionFromTar(t *testing.T) {
+	archive := getTestEarnerLinesTar(t, 1716681600000)
+
+	distro, err := distribution.LoadDistributionFromTar(archive)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1716681600000), distro.Snapshot)
+
+	expected := distribution.NewDistribution()
+	err = expected.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	assert.Equal(t, 596, expected.Len())
+	assert.True(t, expected.Equal(distro))
+}
+
+func TestLoadDistributionFromTarInvalid(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	content := getSortedTestEarnerLines()[1] + "\n" + `{"earner":` + "\n"
+	err := tw.WriteHeader(&tar.Header{Name: "broken.json", Mode: 0o644, Size: int64(len(content))})
+	assert.NoError(t, err)
+	_, err = tw.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+
+	_, err = distribution.LoadDistributionFromTar(&archive)
+	assert.ErrorContains(t, err, "tar entry broken.json")
+	assert.ErrorContains(t, err, "line 2")
+
+	_, err = distribution.LoadDistributionFromTar(strings.NewReader("not a tar archive"))
+	assert.Error(t, err)
+}
+
+func TestLoadDistributionFromTarLineErrors(t *testing.T) {
+	line := func(token, snapshot, amount string) string {
+		return `{"earner":"0x1920fc4fbfdce7c9a438e266d1bc53fd63c4b665","token":"` + token + `","snapshot":` + snapshot + `,"cumulative_amount":"` + amount + `"}`
+	}
+	first := line("0x94373a4919b3240d86ea41593d5eba789fef3848", "1716681600000", "1")
+	other := "0xa2f77c34ec2468b902863992630b7d83e674e49a"
+
+	for _, tc := range []struct {
+		name    string
+		second  string
+		err     error
+		field   string
+		message string
+	}{
+		{"amount", line(other, "1716681600000", "-1"), distribution.ErrNegativeAmount, "cumulative_amount", "tar entry b.json"},
+		{"snapshot", line(other, "1712102400000", "1"), distribution.ErrSnapshotMismatch, "snapshot", "tar entry b.json"},
+		{"duplicate", first, distribution.ErrDuplicateEntry, "", "first: a.json line 2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var archive bytes.Buffer
+			tw := tar.NewWriter(&archive)
+			for _, entry := range []struct{ name, content string }{
+				{"a.json", "\n" + first + "\n"},
+				{"b.json", "\n\n" + tc.second + "\n"},
+			} {
+				err := tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.content))})
+				assert.NoError(t, err)
+				_, err = tw.Write([]byte(entry.content))
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, tw.Close())
+
+			_, err := distribution.LoadDistributionFromTar(&archive)
+			assert.ErrorIs(t, err, tc.err)
+			assert.ErrorContains(t, err, "tar entry b.json")
+			assert.ErrorContains(t, err, tc.message)
+			var parseErr *distribution.ParseError
+			if assert.ErrorAs(t, err, &parseErr) {
+				assert.Equal(t, 3, parseErr.LineNumber)
+				assert.Equal(t, tc.field, parseErr.Field)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	lines := getSortedTestEarnerLines()[1:4]
+	malformed := strings.Replace(lines[1], `"cumulative_amount":"`, `"cumulative_amount":"1.5x`, 1)
+	assert.NotEqual(t, lines[1], malformed)
+	raw := strings.Join([]string{lines[0], "", malformed, lines[2]}, "\n")
+
+	// the streaming loaders report the line of the input, blank lines included
+	var parseErr *distribution.ParseError
+	err := distribution.NewDistribution().LoadLinesFromReader(strings.NewReader(raw))
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 3, parseErr.LineNumber)
+	assert.Equal(t, "cumulative_amount", parseErr.Field)
+	amount := parseTestEarnerLines(t, lines[1])[0].CumulativeAmount
+	assert.Equal(t, "1.5x"+amount, parseErr.RawValue)
+	assert.ErrorContains(t, parseErr.Err, "failed to parse cumulative reward")
+
+	// LoadLines reports the position in the slice it was given rather than in sorted order
+	earnerLines := parseTestEarnerLines(t, strings.Join(lines, "\n"))
+	earnerLines[0], earnerLines[2] = earnerLines[2], earnerLines[0]
+	earnerLines[0].CumulativeAmount = "-1"
+	err = distribution.NewDistribution().LoadLines(earnerLines)
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 1, parseErr.LineNumber)
+	assert.Equal(t, "cumulative_amount", parseErr.Field)
+	assert.Equal(t, "-1", parseErr.RawValue)
+	assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+
+	// as are snapshot mismatches and duplicates
+	earnerLines = parseTestEarnerLines(t, strings.Join(lines, "\n"))
+	earnerLines[2].Snapshot++
+	err = distribution.NewDistribution().LoadLines(earnerLines)
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 3, parseErr.LineNumber)
+	assert.Equal(t, "snapshot", parseErr.Field)
+	assert.Equal(t, "1716681600001", parseErr.RawValue)
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+
+	earnerLines = parseTestEarnerLines(t, strings.Join([]string{lines[0], lines[1], lines[0]}, "\n"))
+	err = distribution.NewDistribution().LoadLinesStrict(earnerLines)
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Contains(t, []int{1, 3}, parseErr.LineNumber)
+	assert.Empty(t, parseErr.Field)
+	assert.ErrorIs(t, err, distribution.ErrDuplicateEntry)
+
+	// lines that are not valid JSON keep the raw line
+	err = distribution.NewDistribution().LoadLinesFromReader(strings.NewReader(lines[0] + "\n" + `{"earner":`))
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, 2, parseErr.LineNumber)
+	assert.Empty(t, parseErr.Field)
+	assert.Equal(t, `{"earner":`, parseErr.RawValue)
+
+	err = distribution.NewDistribution().LoadLinesFromReader(strings.NewReader(`{"earner":1}`))
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "earner", parseErr.Field)
+	assert.Equal(t, `{"earner":1}`, parseErr.RawValue)
+
+	// and invalid snapshots are attributed to their field
+	err = distribution.NewDistribution().LoadLinesFromReader(strings.NewReader(`{"snapshot":1716681600}`))
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "snapshot", parseErr.Field)
+	assert.Equal(t, "1716681600", parseErr.RawValue)
+	assert.ErrorIs(t, err, distribution.ErrInvalidSnapshot)
+}
+
+func TestStreamingLoadersSnapshotMismatch(t *testing.T) {
+	earner := "0x1920fc4fbfdce7c9a438e266d1bc53fd63c4b665"
+	raw := strings.Join([]string{
+		`{"earner":"` + earner + `","token":"0x94373a4919b3240d86ea41593d5eba789fef3848","snapshot":1716681600000,"cumulative_amount":"1"}`,
+		`{"earner":"` + earner + `","token":"0xa2f77c34ec2468b902863992630b7d83e674e49a","snapshot":1712102400000,"cumulative_amount":"1"}`,
+	}, "\n")
+
+	assertMismatch := func(t *testing.T, d *distribution.Distribution, err error) {
+		assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+		var parseErr *distribution.ParseError
+		if assert.ErrorAs(t, err, &parseErr) {
+			assert.Equal(t, 2, parseErr.LineNumber)
+			assert.Equal(t, "snapshot", parseErr.Field)
+			assert.Equal(t, "1712102400000", parseErr.RawValue)
+		}
+		// the snapshot is taken from the first line
+		assert.Equal(t, uint64(1716681600000), d.Snapshot)
+	}
+
+	t.Run("reader", func(t *testing.T) {
+		d := distribution.NewDistribution()
+		assertMismatch(t, d, d.LoadLinesFromReader(strings.NewReader(raw)))
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		_, err := gz.Write([]byte(raw))
+		assert.NoError(t, err)
+		assert.NoError(t, gz.Close())
+
+		d := distribution.NewDistribution()
+		assertMismatch(t, d, d.LoadLinesFromGzip(&compressed))
+	})
+
+	t.Run("iterator", func(t *testing.T) {
+		d := distribution.NewDistribution()
+		assertMismatch(t, d, d.LoadFromIterator(sliceIterator(parseTestEarnerLines(t, raw))))
+	})
+
+	// a snapshot set beforehand is enforced from the first line
+	d := distribution.NewDistribution()
+	d.Snapshot = 1712102400000
+	err := d.LoadLinesFromReader(strings.NewReader(raw))
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+	assert.ErrorContains(t, err, "line 1")
+}
@@ -0,0 +1,246 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// Merge adds the amounts of other into the distribution. Amounts for earner/token pairs
+// present in both distributions are summed, pairs present in only one are carried over.
+//
+// The merged pairs are sorted before being set, so the distributions may be merged in any
+// order. Merklized distributions are rejected since their indices would become stale.
+func (d *Distribution) Merge(other *Distribution) error {
+	if d.isMerklized() || other.isMerklized() {
+		return ErrDistributionMerklized
+	}
+
+	amounts := make(map[gethcommon.Address]map[gethcommon.Address]*big.Int)
+	for _, distro := range []*Distribution{d, other} {
+		for accountPair := distro.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+			tokens, found := amounts[accountPair.Key]
+			if !found {
+				tokens = make(map[gethcommon.Address]*big.Int)
+				amounts[accountPair.Key] = tokens
+			}
+
+			for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+				amount, found := tokens[tokenPair.Key]
+				if !found {
+					amount = new(big.Int)
+					tokens[tokenPair.Key] = amount
+				}
+				// nil amounts are treated as zero
+				amount.Add(amount, amountOrZero(tokenPair.Value))
+			}
+		}
+	}
+
+	merged, err := newDistributionFromAmounts(amounts)
+	if err != nil {
+		return err
+	}
+	d.data = merged.data
+	return nil
+}
+
+// Subtract returns a new distribution holding the amount of each earner/token pair minus its
+// amount in previous. Pairs missing from previous are carried over unchanged.
+//
+// Cumulative amounts never decrease, so any pair whose previous amount exceeds the current
+// one, including pairs missing from the current distribution, results in an error.
+func (d *Distribution) Subtract(previous *Distribution) (*Distribution, error) {
+	for accountPair := previous.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			if _, found := d.Get(accountPair.Key, tokenPair.Key); !found && amountOrZero(tokenPair.Value).Sign() > 0 {
+				return nil, fmt.Errorf("%w - earner: %s, token: %s, previous: %s, current: 0",
+					ErrAmountDecreased, accountPair.Key.Hex(), tokenPair.Key.Hex(), amountOrZero(tokenPair.Value).String())
+			}
+		}
+	}
+
+	delta := d.newEmpty()
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			amount := new(big.Int).Set(amountOrZero(tokenPair.Value))
+
+			previousAmount, found := previous.Get(accountPair.Key, tokenPair.Key)
+			if found && previousAmount != nil {
+				if previousAmount.Cmp(amount) > 0 {
+					return nil, fmt.Errorf("%w - earner: %s, token: %s, previous: %s, current: %s",
+						ErrAmountDecreased, accountPair.Key.Hex(), tokenPair.Key.Hex(), previousAmount.String(), amount.String())
+				}
+				amount.Sub(amount, previousAmount)
+			}
+
+			if err := delta.Set(accountPair.Key, tokenPair.Key, amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return delta, nil
+}
+
+// PeriodDistributions turns cumulative distributions into the amounts earned between consecutive
+// snapshots, such as each week: the distributions are ordered by Snapshot and each one is subtracted
+// from the next with Subtract, so n snapshots give n-1 periods, each with the Snapshot of its end. The
+// first period starts at the first snapshot, whatever was earned before it is not included.
+//
+// ErrInvalidSnapshot is returned for a distribution without a Snapshot and ErrDuplicateSnapshot for two
+// with the same one. Amounts that decrease from one snapshot to the next are rejected with
+// ErrAmountDecreased. Nil distributions are skipped.
+func PeriodDistributions(snapshots []*Distribution) ([]*Distribution, error) {
+	sorted := make([]*Distribution, 0, len(snapshots))
+	for i, d := range snapshots {
+		if d == nil {
+			continue
+		}
+		if d.Snapshot == 0 {
+			return nil, fmt.Errorf("%w - distribution: %d has no snapshot", ErrInvalidSnapshot, i)
+		}
+		sorted = append(sorted, d)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Snapshot < sorted[j].Snapshot })
+
+	periods := make([]*Distribution, 0, max(len(sorted)-1, 0))
+	for i := 1; i < len(sorted); i++ {
+		previous, current := sorted[i-1], sorted[i]
+		if previous.Snapshot == current.Snapshot {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateSnapshot, current.Snapshot)
+		}
+		period, err := current.Subtract(previous)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subtract snapshot %d from snapshot %d: %w", previous.Snapshot, current.Snapshot, err)
+		}
+		periods = append(periods, period)
+	}
+	return periods, nil
+}
+
+// Scale returns a new distribution with every amount multiplied by numerator / denominator, such as
+// 11 / 10 for a 10% top-up. Each amount is multiplied before being divided, and the integer division
+// rounds toward zero, so the scaled total may be less than the total scaled by up to one unit per pair.
+//
+// ErrZeroDenominator is returned for a zero denominator and ErrNegativeAmount for a negative factor,
+// nil is treated as zero. Amounts that no longer fit in 256 bits are rejected with ErrAmountOverflow.
+func (d *Distribution) Scale(numerator, denominator *big.Int) (*Distribution, error) {
+	if numerator == nil {
+		numerator = new(big.Int)
+	}
+	if denominator == nil || denominator.Sign() == 0 {
+		return nil, ErrZeroDenominator
+	}
+	if numerator.Sign() < 0 || denominator.Sign() < 0 {
+		return nil, fmt.Errorf("%w - factor: %s/%s", ErrNegativeAmount, numerator.String(), denominator.String())
+	}
+
+	scaled := d.newEmpty()
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			amount := new(big.Int).Mul(amountOrZero(tokenPair.Value), numerator)
+			amount.Quo(amount, denominator)
+			if err := scaled.Set(accountPair.Key, tokenPair.Key, amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return scaled, nil
+}
+
+// MergeDistributions sums the amounts of every distribution into a new one, like calling Merge with
+// each of them but in a single pass: the earners, and then the tokens of each earner, are already in
+// order in every source, so they are merged by repeatedly taking the smallest key across the sources.
+// The result has the configuration of the first distribution.
+//
+// Merklized distributions are rejected with ErrDistributionMerklized, as with Merge, and sums that
+// do not fit in 256 bits with ErrAmountOverflow. Nil distributions are skipped.
+func MergeDistributions(ds ...*Distribution) (*Distribution, error) {
+	merged, _, err := MergeDistributionsWithOverlaps(ds...)
+	return merged, err
+}
+
+// MergeDistributionsWithOverlaps behaves like MergeDistributions and also returns the earners whose
+// tokens came from more than one distribution, in order. Their token lists are interleaved from the
+// sources and set in order like every other pair, so a merged earner's tokens are always sorted and
+// complete. The earners are printed when the first distribution has Debug set.
+func MergeDistributionsWithOverlaps(ds ...*Distribution) (*Distribution, []gethcommon.Address, error) {
+	sources := make([]*Distribution, 0, len(ds))
+	for i, d := range ds {
+		if d == nil {
+			continue
+		}
+		if d.isMerklized() {
+			return nil, nil, fmt.Errorf("%w - distribution: %d", ErrDistributionMerklized, i)
+		}
+		sources = append(sources, d)
+	}
+	if len(sources) == 0 {
+		return NewDistribution(), nil, nil
+	}
+
+	merged := sources[0].newEmpty()
+	overlaps := make([]gethcommon.Address, 0)
+	accountCursors := make([]*orderedmap.Pair[gethcommon.Address, *orderedmap.OrderedMap[gethcommon.Address, *BigInt]], len(sources))
+	for i, d := range sources {
+		accountCursors[i] = d.data.Oldest()
+	}
+	tokenCursors := make([]*orderedmap.Pair[gethcommon.Address, *BigInt], len(sources))
+	for {
+		earner, found := smallestKey(accountCursors)
+		if !found {
+			break
+		}
+		earnerSources := 0
+		for i, cursor := range accountCursors {
+			tokenCursors[i] = nil
+			if cursor != nil && cursor.Key == earner {
+				tokenCursors[i] = cursor.Value.Oldest()
+				accountCursors[i] = cursor.Next()
+				earnerSources++
+			}
+		}
+		if earnerSources > 1 {
+			overlaps = append(overlaps, earner)
+		}
+
+		for {
+			token, found := smallestKey(tokenCursors)
+			if !found {
+				break
+			}
+			amount := new(big.Int)
+			for i, cursor := range tokenCursors {
+				if cursor != nil && cursor.Key == token {
+					amount.Add(amount, amountOrZero(cursor.Value))
+					tokenCursors[i] = cursor.Next()
+				}
+			}
+			// Set rejects tokens out of order, so a source that is not sorted cannot produce an unsorted earner
+			if err := merged.Set(earner, token, amount); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if merged.Debug {
+		fmt.Printf("MergeDistributions: %d earners have tokens from more than one distribution: %v\n", len(overlaps), overlaps)
+	}
+	return merged, overlaps, nil
+}
+
+// smallestKey returns the smallest key among the non nil cursors, or false if they are all nil
+func smallestKey[V any](cursors []*orderedmap.Pair[gethcommon.Address, V]) (gethcommon.Address, bool) {
+	var smallest gethcommon.Address
+	found := false
+	for _, cursor := range cursors {
+		if cursor != nil && (!found || CompareAddresses(cursor.Key, smallest) < 0) {
+			smallest = cursor.Key
+			found = true
+		}
+	}
+	return smallest, found
+}
@@ -0,0 +1,331 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.Set(tests.TestAddresses[1], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	err = d.Set(tests.TestAddresses[1], tests.TestTokens[2], big.NewInt(2))
+	assert.NoError(t, err)
+
+	other := distribution.NewDistribution()
+	err = other.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(3))
+	assert.NoError(t, err)
+	err = other.Set(tests.TestAddresses[1], tests.TestTokens[1], big.NewInt(4))
+	assert.NoError(t, err)
+	err = other.Set(tests.TestAddresses[1], tests.TestTokens[2], big.NewInt(5))
+	assert.NoError(t, err)
+
+	err = d.Merge(other)
+	assert.NoError(t, err)
+
+	// keys only in other are inserted ahead of existing keys
+	amount, found := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(3), amount)
+
+	amount, found = d.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(1), amount)
+
+	amount, found = d.Get(tests.TestAddresses[1], tests.TestTokens[1])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(4), amount)
+
+	// matching keys are summed
+	amount, found = d.Get(tests.TestAddresses[1], tests.TestTokens[2])
+	assert.True(t, found)
+	assert.Equal(t, big.NewInt(7), amount)
+
+	// other is left untouched
+	amount, _ = other.Get(tests.TestAddresses[1], tests.TestTokens[2])
+	assert.Equal(t, big.NewInt(5), amount)
+
+	_, _, err = d.Merklize()
+	assert.NoError(t, err)
+}
+
+func TestMergeMerklized(t *testing.T) {
+	d := GetTestDistribution()
+	other := GetCompleteTestDistribution()
+
+	_, _, err := other.Merklize()
+	assert.NoError(t, err)
+
+	err = d.Merge(other)
+	assert.ErrorIs(t, err, distribution.ErrDistributionMerklized)
+
+	err = other.Merge(d)
+	assert.ErrorIs(t, err, distribution.ErrDistributionMerklized)
+}
+
+func TestMergeDistributions(t *testing.T) {
+	large := getLargeTestDistribution(50)
+	sources := []*distribution.Distribution{GetTestDistribution(), GetCompleteTestDistribution(), large}
+
+	merged, err := distribution.MergeDistributions(sources...)
+	assert.NoError(t, err)
+
+	expected := distribution.NewDistribution()
+	for _, d := range sources {
+		err := expected.Merge(d)
+		assert.NoError(t, err)
+	}
+	assert.True(t, expected.Equal(merged))
+	assert.Equal(t, len(tests.TestAddresses)+50, merged.EarnerCount())
+
+	// the sources are left untouched
+	assert.True(t, GetTestDistribution().Equal(sources[0]))
+	assert.True(t, getLargeTestDistribution(50).Equal(large))
+
+	_, _, err = merged.Merklize()
+	assert.NoError(t, err)
+
+	empty, err := distribution.MergeDistributions()
+	assert.NoError(t, err)
+	assert.Zero(t, empty.Len())
+}
+
+func TestMergeDistributionsErrors(t *testing.T) {
+	merklized := GetTestDistribution()
+	_, _, err := merklized.Merklize()
+	assert.NoError(t, err)
+	_, err = distribution.MergeDistributions(GetCompleteTestDistribution(), merklized)
+	assert.ErrorIs(t, err, distribution.ErrDistributionMerklized)
+	assert.ErrorContains(t, err, "distribution: 1")
+
+	maxAmount := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	full := distribution.NewDistribution()
+	err = full.Set(tests.TestAddresses[0], tests.TestTokens[0], maxAmount)
+	assert.NoError(t, err)
+	_, err = distribution.MergeDistributions(full, GetTestDistribution())
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+	assert.ErrorContains(t, err, tests.TestAddresses[0].Hex())
+}
+
+func TestMergeDistributionsWithOverlaps(t *testing.T) {
+	// both distributions hold the second earner, with disjoint tokens that interleave
+	first := distribution.NewDistribution()
+	second := distribution.NewDistribution()
+	err := second.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(20))
+	assert.NoError(t, err)
+	for j, token := range tests.TestTokens {
+		d := first
+		if j%2 == 1 {
+			d = second
+		}
+		err := d.Set(tests.TestAddresses[1], token, big.NewInt(int64(j+1)))
+		assert.NoError(t, err)
+	}
+	err = first.Set(tests.TestAddresses[2], tests.TestTokens[0], big.NewInt(10))
+	assert.NoError(t, err)
+
+	merged, overlaps, err := distribution.MergeDistributionsWithOverlaps(first, second)
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{tests.TestAddresses[1]}, overlaps)
+
+	assert.Equal(t, tests.TestAddresses[:3], merged.Earners())
+	assert.Equal(t, tests.TestTokens, merged.TokensForEarner(tests.TestAddresses[1]))
+	for j, token := range tests.TestTokens {
+		amount, found := merged.Get(tests.TestAddresses[1], token)
+		assert.True(t, found)
+		assert.Equal(t, big.NewInt(int64(j+1)), amount)
+	}
+	assert.Empty(t, merged.Validate())
+
+	_, overlaps, err = distribution.MergeDistributionsWithOverlaps(first, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, overlaps)
+}
+
+func TestSubtract(t *testing.T) {
+	current := GetCompleteTestDistribution()
+	previous := GetTestDistribution()
+
+	delta, err := current.Subtract(previous)
+	assert.NoError(t, err)
+
+	for i := 0; i < len(tests.TestAddresses); i++ {
+		for j := 0; j < len(tests.TestTokens); j++ {
+			amount, found := delta.Get(tests.TestAddresses[i], tests.TestTokens[j])
+			assert.True(t, found)
+
+			if j < len(tests.TestTokens)-i {
+				// complete distribution holds j+i+2, test distribution holds j+i+1
+				assert.Equal(t, big.NewInt(1), amount)
+			} else {
+				// only present in the current distribution, passed through unchanged
+				assert.Equal(t, big.NewInt(int64(j+i+2)), amount)
+			}
+		}
+	}
+}
+
+func TestSubtractDecreasedAmount(t *testing.T) {
+	current := distribution.NewDistribution()
+	err := current.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+
+	previous := distribution.NewDistribution()
+	err = previous.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(2))
+	assert.NoError(t, err)
+
+	_, err = current.Subtract(previous)
+	assert.ErrorIs(t, err, distribution.ErrAmountDecreased)
+	assert.ErrorContains(t, err, tests.TestAddresses[0].Hex())
+	assert.ErrorContains(t, err, tests.TestTokens[0].Hex())
+}
+
+func TestSubtractMissingFromCurrent(t *testing.T) {
+	current := distribution.NewDistribution()
+	err := current.Set(tests.TestAddresses[1], tests.TestTokens[1], big.NewInt(5))
+	assert.NoError(t, err)
+
+	previous := distribution.NewDistribution()
+	err = previous.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+
+	_, err = current.Subtract(previous)
+	assert.ErrorIs(t, err, distribution.ErrAmountDecreased)
+	assert.ErrorContains(t, err, tests.TestAddresses[0].Hex())
+}
+
+func TestScale(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+
+	scaled, err := d.Scale(big.NewInt(11), big.NewInt(10))
+	assert.NoError(t, err)
+	assert.Equal(t, d.Len(), scaled.Len())
+	assert.Equal(t, d.Snapshot, scaled.Snapshot)
+
+	// 2690822690822645700000000000 is exactly divisible by 10
+	earner := common.HexToAddress("0xd37f737629e0ddad7fc8adc7247d2e79c0296c35")
+	token := common.HexToAddress("0xe1b7a1249c71b538cc183b0080ffc3efd02bffb9")
+	amount, found := scaled.Get(earner, token)
+	assert.True(t, found)
+	expected, _ := new(big.Int).SetString("2959904959904910270000000000", 10)
+	assert.Equal(t, expected, amount)
+
+	// each pair rounds toward zero, so the scaled total is at most one unit per pair below the exact one
+	counts := make(map[common.Address]int64)
+	for _, earner := range d.Earners() {
+		for _, token := range d.TokensForEarner(earner) {
+			counts[token]++
+		}
+	}
+	scaledTotals := scaled.TokenTotals()
+	for token, total := range d.TokenTotals() {
+		exact := new(big.Int).Mul(total, big.NewInt(11))
+		actual := new(big.Int).Mul(scaledTotals[token], big.NewInt(10))
+		assert.True(t, actual.Cmp(exact) <= 0, "token %s", token.Hex())
+		assert.True(t, actual.Cmp(new(big.Int).Sub(exact, big.NewInt(10*counts[token]))) > 0, "token %s", token.Hex())
+	}
+
+	// the source is left unchanged
+	amount, _ = d.Get(earner, token)
+	assert.Equal(t, "2690822690822645700000000000", amount.String())
+}
+
+func TestScaleRounding(t *testing.T) {
+	d := distribution.NewDistribution()
+	assert.NoError(t, d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(19)))
+
+	scaled, err := d.Scale(big.NewInt(1), big.NewInt(10))
+	assert.NoError(t, err)
+	amount, _ := scaled.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(1), amount)
+
+	scaled, err = d.Scale(nil, big.NewInt(3))
+	assert.NoError(t, err)
+	amount, _ = scaled.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, 0, amount.Sign())
+
+	_, err = d.Scale(big.NewInt(11), big.NewInt(0))
+	assert.ErrorIs(t, err, distribution.ErrZeroDenominator)
+	_, err = d.Scale(big.NewInt(11), nil)
+	assert.ErrorIs(t, err, distribution.ErrZeroDenominator)
+	_, err = d.Scale(big.NewInt(-1), big.NewInt(10))
+	assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+	_, err = d.Scale(big.NewInt(1), big.NewInt(-10))
+	assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+
+	maxAmount := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	assert.NoError(t, d.Set(tests.TestAddresses[0], tests.TestTokens[0], maxAmount))
+	_, err = d.Scale(big.NewInt(2), big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+}
+
+func TestPeriodDistributions(t *testing.T) {
+	first := GetTestDistribution()
+	first.Snapshot = 1716422400000
+	second := GetCompleteTestDistribution()
+	second.Snapshot = 1716681600000
+
+	// given out of order
+	periods, err := distribution.PeriodDistributions([]*distribution.Distribution{second, first})
+	assert.NoError(t, err)
+	assert.Len(t, periods, 1)
+	period := periods[0]
+	assert.Equal(t, uint64(1716681600000), period.Snapshot)
+	assert.Equal(t, second.Len(), period.Len())
+	for i, earner := range tests.TestAddresses {
+		for j, token := range tests.TestTokens {
+			amount, found := period.Get(earner, token)
+			assert.True(t, found)
+			if j < len(tests.TestTokens)-i {
+				// earned 1 since the first snapshot
+				assert.Equal(t, big.NewInt(1), amount)
+			} else {
+				// first earned in the period
+				assert.Equal(t, big.NewInt(int64(j+i+2)), amount)
+			}
+		}
+	}
+
+	// each period counts from the previous snapshot
+	third := GetCompleteTestDistribution()
+	third.Snapshot = 1716768000000
+	assert.NoError(t, third.Add(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(10)))
+	periods, err = distribution.PeriodDistributions([]*distribution.Distribution{first, second, nil, third})
+	assert.NoError(t, err)
+	assert.Len(t, periods, 2)
+	amount, _ := periods[1].Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(10), amount)
+	amount, found := periods[1].Get(tests.TestAddresses[1], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Zero(t, amount.Sign())
+
+	periods, err = distribution.PeriodDistributions([]*distribution.Distribution{first})
+	assert.NoError(t, err)
+	assert.Empty(t, periods)
+}
+
+func TestPeriodDistributionsErrors(t *testing.T) {
+	first := GetCompleteTestDistribution()
+	first.Snapshot = 1716422400000
+	second := GetTestDistribution()
+	second.Snapshot = 1716681600000
+
+	// the amounts of the later snapshot are lower
+	_, err := distribution.PeriodDistributions([]*distribution.Distribution{first, second})
+	assert.ErrorIs(t, err, distribution.ErrAmountDecreased)
+	assert.ErrorContains(t, err, "snapshot 1716422400000 from snapshot 1716681600000")
+
+	second.Snapshot = first.Snapshot
+	_, err = distribution.PeriodDistributions([]*distribution.Distribution{second, first})
+	assert.ErrorIs(t, err, distribution.ErrDuplicateSnapshot)
+
+	_, err = distribution.PeriodDistributions([]*distribution.Distribution{first, GetTestDistribution()})
+	assert.ErrorIs(t, err, distribution.ErrInvalidSnapshot)
+}
@@ -0,0 +1,51 @@
+package distribution
+
+import (
+	"math/big"
+	"strings"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// TokenInfo describes a token for display purposes.
+type TokenInfo struct {
+	Symbol   string
+	Decimals uint8
+}
+
+// FormatAmount formats an amount of a token scaled by its decimals from TokenMetadata,
+// such as 0.006102895758009265 for 6102895758009265 with 18 decimals. Amounts of tokens
+// without metadata are formatted unscaled.
+func (d *Distribution) FormatAmount(token gethcommon.Address, amount *big.Int) string {
+	info, found := d.TokenMetadata[token]
+	if !found {
+		return FormatUnits(amount, 0)
+	}
+	return FormatUnits(amount, info.Decimals)
+}
+
+// FormatUnits formats an integer amount as a decimal number with the given number of decimals,
+// without trailing zeros in the fractional part. A nil amount is formatted as zero.
+func FormatUnits(amount *big.Int, decimals uint8) string {
+	if amount == nil {
+		return "0"
+	}
+	sign := ""
+	if amount.Sign() < 0 {
+		sign = "-"
+	}
+	digits := new(big.Int).Abs(amount).String()
+	if decimals == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= int(decimals) {
+		digits = strings.Repeat("0", int(decimals)-len(digits)+1) + digits
+	}
+	whole := digits[:len(digits)-int(decimals)]
+	fraction := strings.TrimRight(digits[len(digits)-int(decimals):], "0")
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
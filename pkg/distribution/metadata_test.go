@@ -0,0 +1,50 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatAmount(t *testing.T) {
+	d := GetTestDistribution()
+	d.TokenMetadata = map[common.Address]distribution.TokenInfo{
+		tests.TestTokens[0]: {Symbol: "EIGEN", Decimals: 18},
+		tests.TestTokens[1]: {Symbol: "USDC", Decimals: 6},
+	}
+
+	assert.Equal(t, "0.006102895758009265", d.FormatAmount(tests.TestTokens[0], big.NewInt(6102895758009265)))
+	assert.Equal(t, "6102895758.009265", d.FormatAmount(tests.TestTokens[1], big.NewInt(6102895758009265)))
+	assert.Equal(t, "6102895758009265", d.FormatAmount(tests.TestTokens[2], big.NewInt(6102895758009265)))
+
+	// metadata does not affect the root
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+}
+
+func TestFormatUnits(t *testing.T) {
+	oneEther, _ := new(big.Int).SetString("1000000000000000000", 10)
+	cases := []struct {
+		amount   *big.Int
+		decimals uint8
+		expected string
+	}{
+		{oneEther, 18, "1"},
+		{new(big.Int).Add(oneEther, big.NewInt(1)), 18, "1.000000000000000001"},
+		{big.NewInt(1), 18, "0.000000000000000001"},
+		{big.NewInt(1500000), 6, "1.5"},
+		{big.NewInt(-1500000), 6, "-1.5"},
+		{big.NewInt(0), 18, "0"},
+		{nil, 18, "0"},
+		{big.NewInt(42), 0, "42"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, distribution.FormatUnits(c.amount, c.decimals))
+	}
+}
@@ -0,0 +1,141 @@
+package distribution
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+// TokenMultiproof proves several token leaves of an earner's token tree at once. Hashes holds only the
+// nodes that cannot be computed from the proven leaves, level by level from the leaves up and in index
+// order within a level, so leaves sharing branches share their siblings rather than repeating them.
+// The leaves are in token index order, and Depth is the number of levels below the token root.
+type TokenMultiproof struct {
+	Earner       gethcommon.Address
+	Depth        int
+	Tokens       []gethcommon.Address
+	TokenIndices []uint64
+	Amounts      []*big.Int
+	Hashes       [][]byte
+}
+
+// GenerateTokenMultiproof returns a multiproof of an earner's tokens against its token root, which the
+// account leaf of AccountProof commits to. The tokens may be given in any order, but at least one and
+// each only once. The distribution must be merklized.
+func (d *Distribution) GenerateTokenMultiproof(earner gethcommon.Address, tokens []gethcommon.Address) (*TokenMultiproof, error) {
+	if !d.isMerklized() {
+		return nil, ErrNotMerklized
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w - earner: %s", ErrNoTokens, earner.Hex())
+	}
+	tokenTree, found := d.tokenTrees[earner]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+	}
+
+	indices := make([]uint64, 0, len(tokens))
+	tokensByIndex := make(map[uint64]gethcommon.Address, len(tokens))
+	for _, token := range tokens {
+		index, found := d.GetTokenIndex(earner, token)
+		if !found {
+			return nil, fmt.Errorf("%w - earner: %s, token: %s", ErrTokenNotFound, earner.Hex(), token.Hex())
+		}
+		if _, found := tokensByIndex[index]; found {
+			return nil, fmt.Errorf("%w - earner: %s, token: %s", ErrDuplicateEntry, earner.Hex(), token.Hex())
+		}
+		tokensByIndex[index] = token
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	allocatedTokens, _ := d.data.Get(earner)
+	proof := &TokenMultiproof{
+		Earner:       earner,
+		Depth:        treeDepth(tokenTree),
+		Tokens:       make([]gethcommon.Address, 0, len(indices)),
+		TokenIndices: indices,
+		Amounts:      make([]*big.Int, 0, len(indices)),
+		Hashes:       make([][]byte, 0),
+	}
+	for _, index := range indices {
+		token := tokensByIndex[index]
+		amount, _ := allocatedTokens.Get(token)
+		proof.Tokens = append(proof.Tokens, token)
+		proof.Amounts = append(proof.Amounts, new(big.Int).Set(amountOrZero(amount)))
+	}
+
+	// the nodes of a level start at its width, as leaves are padded to a power of two
+	level := indices
+	for width := uint64(len(tokenTree.Nodes) / 2); width > 1; width /= 2 {
+		parents := make([]uint64, 0, len(level))
+		for i := 0; i < len(level); i++ {
+			index := level[i]
+			if index%2 == 0 && i+1 < len(level) && level[i+1] == index+1 {
+				// both children are known, the next one is skipped
+				i++
+			} else {
+				proof.Hashes = append(proof.Hashes, copyBytes(tokenTree.Nodes[width+(index^1)]))
+			}
+			parents = append(parents, index/2)
+		}
+		level = parents
+	}
+	return proof, nil
+}
+
+// VerifyTokenMultiproof checks a TokenMultiproof against an earner's token root, hashing with keccak256
+// and encoding the leaves with CurrentVersion like HashLeaf. The token root is proven against the account
+// root separately, with the earner's account proof. Malformed proofs are reported as invalid.
+func VerifyTokenMultiproof(earnerTokenRoot []byte, proof *TokenMultiproof) bool {
+	leafCount := len(proof.TokenIndices)
+	if leafCount == 0 || len(proof.Tokens) != leafCount || len(proof.Amounts) != leafCount || proof.Depth < 0 || proof.Depth >= 64 {
+		return false
+	}
+	hashType := keccak256.New()
+
+	indices := make([]uint64, leafCount)
+	nodes := make([][]byte, leafCount)
+	for i, index := range proof.TokenIndices {
+		amount := proof.Amounts[i]
+		if amount == nil || amount.Sign() < 0 || amount.BitLen() > 256 || index >= uint64(1)<<proof.Depth {
+			return false
+		}
+		if i > 0 && index <= indices[i-1] {
+			return false
+		}
+		indices[i] = index
+		nodes[i] = HashLeaf(EncodeTokenLeaf(proof.Tokens[i], amount))
+	}
+
+	hashes := proof.Hashes
+	for level := 0; level < proof.Depth; level++ {
+		parentIndices := make([]uint64, 0, len(indices))
+		parents := make([][]byte, 0, len(nodes))
+		for i := 0; i < len(indices); i++ {
+			index := indices[i]
+			var left, right []byte
+			switch {
+			case index%2 == 0 && i+1 < len(indices) && indices[i+1] == index+1:
+				left, right = nodes[i], nodes[i+1]
+				i++
+			case len(hashes) == 0:
+				return false
+			case index%2 == 0:
+				left, right = nodes[i], hashes[0]
+				hashes = hashes[1:]
+			default:
+				left, right = hashes[0], nodes[i]
+				hashes = hashes[1:]
+			}
+			parentIndices = append(parentIndices, index/2)
+			parents = append(parents, hashType.Hash(left, right))
+		}
+		indices, nodes = parentIndices, parents
+	}
+	return len(hashes) == 0 && bytes.Equal(nodes[0], earnerTokenRoot)
+}
@@ -0,0 +1,158 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateTokenMultiproof(t *testing.T) {
+	d := GetTestDistribution()
+	_, err := d.GenerateTokenMultiproof(d.Earners()[0], tests.TestTokens)
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	_, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	// the first earner has all 5 tokens, a tree of 8 leaves
+	earner := d.Earners()[0]
+	tokens := d.TokensForEarner(earner)
+	assert.Len(t, tokens, 5)
+	root := tokenTrees[earner].Root()
+
+	proof, err := d.GenerateTokenMultiproof(earner, tokens)
+	assert.NoError(t, err)
+	assert.True(t, distribution.VerifyTokenMultiproof(root, proof))
+	assert.Equal(t, 3, proof.Depth)
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4}, proof.TokenIndices)
+
+	// the individual proofs hold 3 hashes each, the multiproof only the padding sibling of the last token
+	// and the branch above it
+	individual := 0
+	for i, token := range tokens {
+		tokenProof, _, err := d.TokenProof(earner, token)
+		assert.NoError(t, err)
+		individual += len(tokenProof)
+
+		amount, _ := d.Get(earner, token)
+		assert.Equal(t, token, proof.Tokens[i])
+		assert.Equal(t, amount, proof.Amounts[i])
+	}
+	assert.Equal(t, 15, individual)
+	assert.Len(t, proof.Hashes, 2)
+
+	// a subset given out of order, leaves 0 and 3 have distinct siblings but their parents are siblings
+	proof, err = d.GenerateTokenMultiproof(earner, []common.Address{tokens[3], tokens[0]})
+	assert.NoError(t, err)
+	assert.Equal(t, []common.Address{tokens[0], tokens[3]}, proof.Tokens)
+	assert.Len(t, proof.Hashes, 3)
+	assert.True(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	// a single token is its regular proof
+	proof, err = d.GenerateTokenMultiproof(earner, tokens[2:3])
+	assert.NoError(t, err)
+	tokenProof, _, err := d.TokenProof(earner, tokens[2])
+	assert.NoError(t, err)
+	assert.Equal(t, tokenProof, proof.Hashes)
+	assert.True(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	// an earner with a single token has an empty proof
+	single := d.Earners()[4]
+	proof, err = d.GenerateTokenMultiproof(single, d.TokensForEarner(single))
+	assert.NoError(t, err)
+	assert.Empty(t, proof.Hashes)
+	assert.True(t, distribution.VerifyTokenMultiproof(tokenTrees[single].Root(), proof))
+}
+
+func TestGenerateTokenMultiproofFixture(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	_, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	for _, earner := range d.Earners() {
+		tokens := d.TokensForEarner(earner)
+		proof, err := d.GenerateTokenMultiproof(earner, tokens)
+		assert.NoError(t, err)
+		assert.True(t, distribution.VerifyTokenMultiproof(tokenTrees[earner].Root(), proof), "earner %s", earner.Hex())
+
+		individual := 0
+		for _, token := range tokens {
+			tokenProof, _, err := d.TokenProof(earner, token)
+			assert.NoError(t, err)
+			individual += len(tokenProof)
+		}
+		assert.LessOrEqual(t, len(proof.Hashes), individual)
+		if len(tokens) > 1 {
+			assert.Less(t, len(proof.Hashes), individual, "earner %s", earner.Hex())
+		}
+	}
+}
+
+func TestVerifyTokenMultiproofTampered(t *testing.T) {
+	d := GetTestDistribution()
+	_, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+	earner := d.Earners()[0]
+	root := tokenTrees[earner].Root()
+	tokens := d.TokensForEarner(earner)
+
+	generate := func() *distribution.TokenMultiproof {
+		proof, err := d.GenerateTokenMultiproof(earner, []common.Address{tokens[1], tokens[2], tokens[4]})
+		assert.NoError(t, err)
+		assert.True(t, distribution.VerifyTokenMultiproof(root, proof))
+		return proof
+	}
+
+	proof := generate()
+	proof.Amounts[1] = new(big.Int).Add(proof.Amounts[1], big.NewInt(1))
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	proof = generate()
+	proof.Tokens[0], proof.Tokens[1] = proof.Tokens[1], proof.Tokens[0]
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	proof = generate()
+	proof.TokenIndices[2] = 5
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	proof = generate()
+	proof.Hashes = proof.Hashes[:len(proof.Hashes)-1]
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	proof = generate()
+	proof.Hashes = append(proof.Hashes, make([]byte, 32))
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	proof = generate()
+	proof.Depth = 2
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	proof = generate()
+	proof.TokenIndices[0], proof.TokenIndices[1] = proof.TokenIndices[1], proof.TokenIndices[0]
+	assert.False(t, distribution.VerifyTokenMultiproof(root, proof))
+
+	assert.False(t, distribution.VerifyTokenMultiproof(root, &distribution.TokenMultiproof{}))
+}
+
+func TestGenerateTokenMultiproofErrors(t *testing.T) {
+	d := GetTestDistribution()
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	earner := d.Earners()[0]
+	token := d.TokensForEarner(earner)[0]
+	_, err = d.GenerateTokenMultiproof(earner, nil)
+	assert.ErrorIs(t, err, distribution.ErrNoTokens)
+	_, err = d.GenerateTokenMultiproof(common.HexToAddress("0x01"), []common.Address{token})
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+	_, err = d.GenerateTokenMultiproof(earner, []common.Address{token, common.HexToAddress("0x01")})
+	assert.ErrorIs(t, err, distribution.ErrTokenNotFound)
+	_, err = d.GenerateTokenMultiproof(earner, []common.Address{token, token})
+	assert.ErrorIs(t, err, distribution.ErrDuplicateEntry)
+}
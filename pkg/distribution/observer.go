@@ -0,0 +1,42 @@
+package distribution
+
+import "time"
+
+// Observer receives events from the loaders and Merklize, for example to emit metrics without
+// depending on a metrics library. The calls are made from the goroutine doing the work.
+type Observer interface {
+	// OnLoadComplete is called after lines have been loaded with the number of earners and the
+	// number of earner/token pairs in the distribution.
+	OnLoadComplete(earnerCount, tokenCount int)
+	// OnMerklizeStart is called when Merklize starts building the trees, not when they are cached.
+	OnMerklizeStart()
+	// OnMerklizeComplete is called when the trees have been built with the time taken and the account root.
+	OnMerklizeComplete(duration time.Duration, root []byte)
+}
+
+// NopObserver is an Observer that ignores every event, it is used when no observer is set.
+type NopObserver struct{}
+
+func (NopObserver) OnLoadComplete(int, int)                  {}
+func (NopObserver) OnMerklizeStart()                         {}
+func (NopObserver) OnMerklizeComplete(time.Duration, []byte) {}
+
+// WithObserver sets the observer notified by the loaders and Merklize.
+func WithObserver(observer Observer) Option {
+	return func(d *Distribution) {
+		d.observer = observer
+	}
+}
+
+// getObserver returns the observer, or a NopObserver when none is set
+func (d *Distribution) getObserver() Observer {
+	if d.observer == nil {
+		return NopObserver{}
+	}
+	return d.observer
+}
+
+// reportLoadComplete notifies the observer that lines have been loaded
+func (d *Distribution) reportLoadComplete() {
+	d.getObserver().OnLoadComplete(d.EarnerCount(), d.Len())
+}
@@ -0,0 +1,80 @@
+package distribution_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeObserver struct {
+	events      []string
+	earnerCount int
+	tokenCount  int
+	duration    time.Duration
+	root        []byte
+}
+
+func (o *fakeObserver) OnLoadComplete(earnerCount, tokenCount int) {
+	o.events = append(o.events, "load")
+	o.earnerCount = earnerCount
+	o.tokenCount = tokenCount
+}
+
+func (o *fakeObserver) OnMerklizeStart() {
+	o.events = append(o.events, "start")
+}
+
+func (o *fakeObserver) OnMerklizeComplete(duration time.Duration, root []byte) {
+	o.events = append(o.events, "complete")
+	o.duration = duration
+	o.root = root
+}
+
+func TestWithObserver(t *testing.T) {
+	expected := GetTestDistribution()
+	lines := make([]*distribution.EarnerLine, 0)
+	for _, earner := range expected.Earners() {
+		for _, token := range expected.TokensForEarner(earner) {
+			amount, _ := expected.Get(earner, token)
+			lines = append(lines, &distribution.EarnerLine{Earner: earner.Hex(), Token: token.Hex(), CumulativeAmount: amount.String()})
+		}
+	}
+
+	observer := &fakeObserver{}
+	d := distribution.NewDistribution(distribution.WithObserver(observer))
+	err := d.LoadLines(lines)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"load"}, observer.events)
+	assert.Equal(t, 5, observer.earnerCount)
+	assert.Equal(t, 15, observer.tokenCount)
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	expectedRoot, err := expected.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"load", "start", "complete"}, observer.events)
+	assert.Equal(t, expectedRoot, root)
+	assert.Equal(t, root, observer.root)
+	assert.Greater(t, observer.duration, time.Duration(0))
+
+	// cached trees are not reported again
+	_, _, err = d.Merklize()
+	assert.NoError(t, err)
+	assert.Len(t, observer.events, 3)
+
+	// the streaming loader reports too, and copies keep the observer
+	observer = &fakeObserver{}
+	d = distribution.NewDistribution(distribution.WithObserver(observer))
+	err = d.LoadLinesFromReader(strings.NewReader(strings.Join(getSortedTestEarnerLines(), "\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"load"}, observer.events)
+	assert.Equal(t, d.EarnerCount(), observer.earnerCount)
+	assert.Equal(t, 596, observer.tokenCount)
+
+	_, _, err = d.Clone().Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"load", "start", "complete"}, observer.events)
+}
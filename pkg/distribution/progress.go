@@ -0,0 +1,30 @@
+package distribution
+
+// ProgressFunc is called while merklizing with the number of earners processed so far.
+type ProgressFunc func(earnersProcessed, earnersTotal int)
+
+// defaultProgressInterval is the number of earners between progress calls when none is given
+const defaultProgressInterval = 1000
+
+// WithProgress calls progress every interval earners while merklizing, and once more when all
+// earners have been processed, so the last call always reports the total. The calls are made
+// from the goroutine calling Merklize and never concurrently.
+func WithProgress(interval int, progress ProgressFunc) Option {
+	return func(d *Distribution) {
+		if interval <= 0 {
+			interval = defaultProgressInterval
+		}
+		d.progress = progress
+		d.progressInterval = interval
+	}
+}
+
+// reportProgress calls the progress callback if one is set and the interval has been reached
+func (d *Distribution) reportProgress(processed, total int) {
+	if d.progress == nil {
+		return
+	}
+	if processed%d.progressInterval == 0 || processed == total {
+		d.progress(processed, total)
+	}
+}
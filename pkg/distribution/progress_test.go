@@ -0,0 +1,47 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProgress(t *testing.T) {
+	var processed []int
+	d := distribution.NewDistribution(distribution.WithProgress(2, func(earnersProcessed, earnersTotal int) {
+		assert.Equal(t, len(tests.TestAddresses), earnersTotal)
+		processed = append(processed, earnersProcessed)
+	}))
+	for i := 0; i < len(tests.TestAddresses); i++ {
+		for j := 0; j < len(tests.TestTokens)-i; j++ {
+			err := d.Set(tests.TestAddresses[i], tests.TestTokens[j], big.NewInt(int64(j+i+1)))
+			assert.NoError(t, err)
+		}
+	}
+
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 5}, processed)
+
+	// cached trees are not reported again
+	_, _, err = d.Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 5}, processed)
+}
+
+func TestWithProgressDefaultInterval(t *testing.T) {
+	var processed []int
+	progress := distribution.WithProgress(0, func(earnersProcessed, earnersTotal int) {
+		assert.Equal(t, 2500, earnersTotal)
+		processed = append(processed, earnersProcessed)
+	})
+	d := getLargeTestDistribution(2500)
+	progress(d)
+
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1000, 2000, 2500}, processed)
+}
@@ -0,0 +1,218 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/wealdtech/go-merkletree/v2"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// EarnerProof holds the proof of an earner leaf against the account root and the proofs of
+// all of the earner's token leaves against its token root.
+type EarnerProof struct {
+	Earner          gethcommon.Address
+	EarnerIndex     uint64
+	EarnerTokenRoot []byte
+	EarnerTreeProof [][]byte // sibling hashes from the earner leaf up to the account root
+	TokenProofs     []*TokenProof
+}
+
+// TokenProof holds the proof of a token leaf against the token root of its earner.
+type TokenProof struct {
+	Token          gethcommon.Address
+	TokenIndex     uint64
+	Amount         *big.Int
+	TokenTreeProof [][]byte // sibling hashes from the token leaf up to the earner token root
+}
+
+// GenerateAllProofs returns the proofs of every earner and token, merklizing the distribution if needed.
+//
+// The trees are built once and every sibling path is read directly from their nodes by index,
+// so for n leaves generating all proofs is O(n log n) and the sibling hashes are shared with the
+// trees rather than copied. Proving each earner separately also looks up every index and copies
+// every proof, see BenchmarkGenerateAllProofs.
+func (d *Distribution) GenerateAllProofs() (map[gethcommon.Address]*EarnerProof, error) {
+	accountTree, tokenTrees, err := d.Merklize()
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make(map[gethcommon.Address]*EarnerProof, d.data.Len())
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		proof, err := d.earnerProof(accountTree, tokenTrees[accountPair.Key], accountPair)
+		if err != nil {
+			return nil, err
+		}
+		proofs[accountPair.Key] = proof
+	}
+	return proofs, nil
+}
+
+// LoadLinesForEarners loads every line like LoadLines, since the trees must be complete for the roots
+// to match, and records the requested earners so that GenerateProofsForRequested only proves those.
+// Each call replaces the earners requested by the previous one.
+func (d *Distribution) LoadLinesForEarners(lines []*EarnerLine, earners map[gethcommon.Address]bool) error {
+	if err := d.LoadLines(lines); err != nil {
+		return err
+	}
+	d.requested = make(map[gethcommon.Address]bool, len(earners))
+	for earner, requested := range earners {
+		if requested {
+			d.requested[earner] = true
+		}
+	}
+	return nil
+}
+
+// GenerateProofsForRequested returns the proofs of the earners requested with LoadLinesForEarners,
+// merklizing the distribution if needed, rather than of every earner like GenerateAllProofs.
+// ErrEarnerNotFound is returned if a requested earner has no lines.
+func (d *Distribution) GenerateProofsForRequested() (map[gethcommon.Address]*EarnerProof, error) {
+	accountTree, tokenTrees, err := d.Merklize()
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make(map[gethcommon.Address]*EarnerProof, len(d.requested))
+	for earner := range d.requested {
+		accountPair := d.data.GetPair(earner)
+		if accountPair == nil {
+			return nil, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+		}
+		proof, err := d.earnerProof(accountTree, tokenTrees[earner], accountPair)
+		if err != nil {
+			return nil, err
+		}
+		proofs[earner] = proof
+	}
+	return proofs, nil
+}
+
+// earnerProof returns the proofs of an earner and all of its tokens, read from the merklized trees
+func (d *Distribution) earnerProof(accountTree, tokenTree *merkletree.MerkleTree, accountPair *orderedmap.Pair[gethcommon.Address, *orderedmap.OrderedMap[gethcommon.Address, *BigInt]]) (*EarnerProof, error) {
+	// shards hold a subset of the earners, so their indices are looked up rather than counted
+	earnerIndex := d.accountIndices[accountPair.Key]
+	earnerTreeProof, err := accountTree.GenerateProofWithIndex(earnerIndex, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenProofs := make([]*TokenProof, 0, accountPair.Value.Len())
+	tokenIndex := uint64(0)
+	for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+		tokenTreeProof, err := tokenTree.GenerateProofWithIndex(tokenIndex, 0)
+		if err != nil {
+			return nil, err
+		}
+		tokenProofs = append(tokenProofs, &TokenProof{
+			Token:          tokenPair.Key,
+			TokenIndex:     tokenIndex,
+			Amount:         new(big.Int).Set(amountOrZero(tokenPair.Value)),
+			TokenTreeProof: tokenTreeProof.Hashes,
+		})
+		tokenIndex++
+	}
+
+	return &EarnerProof{
+		Earner:          accountPair.Key,
+		EarnerIndex:     earnerIndex,
+		EarnerTokenRoot: tokenTree.Root(),
+		EarnerTreeProof: earnerTreeProof.Hashes,
+		TokenProofs:     tokenProofs,
+	}, nil
+}
+
+// AccountProof returns the sibling hashes from the earner's leaf up to the account root,
+// along with the index of the leaf. The distribution must be merklized.
+func (d *Distribution) AccountProof(earner gethcommon.Address) ([][]byte, uint64, error) {
+	if !d.isMerklized() {
+		return nil, 0, ErrNotMerklized
+	}
+	earnerIndex, found := d.GetAccountIndex(earner)
+	if !found {
+		return nil, 0, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+	}
+	proof, err := d.accountTree.GenerateProofWithIndex(earnerIndex, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return proof.Hashes, earnerIndex, nil
+}
+
+// TokenProof returns the sibling hashes from the token leaf up to the earner's token root,
+// along with the index of the leaf. The distribution must be merklized.
+func (d *Distribution) TokenProof(earner, token gethcommon.Address) ([][]byte, uint64, error) {
+	if !d.isMerklized() {
+		return nil, 0, ErrNotMerklized
+	}
+	tokenTree, found := d.tokenTrees[earner]
+	if !found {
+		return nil, 0, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+	}
+	tokenIndex, found := d.GetTokenIndex(earner, token)
+	if !found {
+		return nil, 0, fmt.Errorf("%w - earner: %s, token: %s", ErrTokenNotFound, earner.Hex(), token.Hex())
+	}
+	proof, err := tokenTree.GenerateProofWithIndex(tokenIndex, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return proof.Hashes, tokenIndex, nil
+}
+
+// claimProofJSONSkeleton is a claim in the JSON format written by claimgen.WriteAllProofs with every
+// value left out, including the trailing newline of the encoder.
+const claimProofJSONSkeleton = `{"rootIndex":,"earnerIndex":,"earnerTreeProof":"0x","earnerLeaf":{"earner":"0x","earnerTokenRoot":"0x"},` +
+	`"tokenIndices":[],"tokenTreeProofs":[],"tokenLeaves":[]}` + "\n"
+
+// claimProofJSONTokenSkeleton is a token leaf of a claim with its values left out, along with the empty
+// proof and the separators between array elements.
+const claimProofJSONTokenSkeleton = `{"token":"0x","cumulativeEarnings":""}` + `"0x"` + ",,,"
+
+// EstimateProofBytes estimates the total size of the claims written by claimgen.WriteAllProofs, merklizing
+// the distribution if needed. The size is computed from the depths of the trees and the amounts without
+// generating any proof, and is exact for a single digit root index.
+func (d *Distribution) EstimateProofBytes() (int64, error) {
+	accountTree, _, err := d.Merklize()
+	if err != nil {
+		return 0, err
+	}
+
+	earnerProofBytes := int64(proofDepth(len(accountTree.Data)) * 32 * 2)
+	fixedBytes := int64(len(claimProofJSONSkeleton)) + 1 + 2*gethcommon.AddressLength + 2*32
+	if d.Snapshot != 0 {
+		fixedBytes += int64(len(`"snapshot":,`) + len(strconv.FormatUint(d.Snapshot, 10)))
+	}
+	if d.CalculationEndTimestamp != 0 {
+		fixedBytes += int64(len(`"calculationEndTimestamp":,`) + len(strconv.FormatUint(d.CalculationEndTimestamp, 10)))
+	}
+
+	total := int64(0)
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		tokenCount := accountPair.Value.Len()
+		tokenProofBytes := int64(proofDepth(tokenCount) * 32 * 2)
+
+		total += fixedBytes + earnerProofBytes + int64(len(strconv.FormatUint(d.accountIndices[accountPair.Key], 10)))
+		tokenIndex := 0
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			total += int64(len(claimProofJSONTokenSkeleton)) + 2*gethcommon.AddressLength + tokenProofBytes +
+				int64(len(strconv.Itoa(tokenIndex))+len(formatAmount(amountOrZero(tokenPair.Value))))
+			tokenIndex++
+		}
+		// there is one separator less than elements in each of the three arrays
+		total -= 3
+	}
+	return total, nil
+}
+
+// proofDepth returns the number of sibling hashes in a proof of a tree with the given number of leaves
+func proofDepth(leaves int) int {
+	if leaves <= 1 {
+		return 0
+	}
+	return bits.Len(uint(leaves - 1))
+}
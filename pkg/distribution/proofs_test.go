@@ -0,0 +1,204 @@
+package distribution_test
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/claimgen"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestGenerateAllProofs(t *testing.T) {
+	d := GetTestDistribution()
+
+	proofs, err := d.GenerateAllProofs()
+	assert.NoError(t, err)
+	assert.Len(t, proofs, len(d.Earners()))
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+
+	for _, earner := range d.Earners() {
+		proof := proofs[earner]
+		assert.Equal(t, earner, proof.Earner)
+
+		earnerIndex, _ := d.GetAccountIndex(earner)
+		assert.Equal(t, earnerIndex, proof.EarnerIndex)
+
+		earnerLeaf := distribution.EncodeAccountLeaf(earner, proof.EarnerTokenRoot)
+		verified, err := merkletree.VerifyProofUsing(earnerLeaf, false, &merkletree.Proof{Hashes: proof.EarnerTreeProof, Index: proof.EarnerIndex}, [][]byte{root}, keccak256.New())
+		assert.NoError(t, err)
+		assert.True(t, verified)
+
+		assert.Len(t, proof.TokenProofs, len(d.TokensForEarner(earner)))
+		for _, tokenProof := range proof.TokenProofs {
+			amount, _ := d.Get(earner, tokenProof.Token)
+			assert.Equal(t, amount, tokenProof.Amount)
+
+			tokenLeaf := distribution.EncodeTokenLeaf(tokenProof.Token, tokenProof.Amount)
+			verified, err := merkletree.VerifyProofUsing(tokenLeaf, false, &merkletree.Proof{Hashes: tokenProof.TokenTreeProof, Index: tokenProof.TokenIndex}, [][]byte{proof.EarnerTokenRoot}, keccak256.New())
+			assert.NoError(t, err)
+			assert.True(t, verified)
+		}
+	}
+}
+
+func TestAccountAndTokenProof(t *testing.T) {
+	d := GetTestDistribution()
+
+	_, _, err := d.AccountProof(tests.TestAddresses[0])
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+	_, _, err = d.TokenProof(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	for i, earner := range d.Earners() {
+		earnerTreeProof, earnerIndex, err := d.AccountProof(earner)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(i), earnerIndex)
+
+		earnerTokenRoot := tokenTrees[earner].Root()
+		earnerLeaf := distribution.EncodeAccountLeaf(earner, earnerTokenRoot)
+		verified, err := merkletree.VerifyProofUsing(earnerLeaf, false, &merkletree.Proof{Hashes: earnerTreeProof, Index: earnerIndex}, [][]byte{accountTree.Root()}, keccak256.New())
+		assert.NoError(t, err)
+		assert.True(t, verified)
+
+		for j, token := range d.TokensForEarner(earner) {
+			tokenTreeProof, tokenIndex, err := d.TokenProof(earner, token)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(j), tokenIndex)
+
+			amount, _ := d.Get(earner, token)
+			tokenLeaf := distribution.EncodeTokenLeaf(token, amount)
+			verified, err := merkletree.VerifyProofUsing(tokenLeaf, false, &merkletree.Proof{Hashes: tokenTreeProof, Index: tokenIndex}, [][]byte{earnerTokenRoot}, keccak256.New())
+			assert.NoError(t, err)
+			assert.True(t, verified)
+		}
+	}
+
+	unknown := common.HexToAddress("0x01")
+	_, _, err = d.AccountProof(unknown)
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+	_, _, err = d.TokenProof(unknown, tests.TestTokens[0])
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+
+	// the last earner only holds the first token
+	_, _, err = d.TokenProof(tests.TestAddresses[4], tests.TestTokens[1])
+	assert.ErrorIs(t, err, distribution.ErrTokenNotFound)
+}
+
+// getLargeTestDistribution returns a distribution with earners holding between one and three tokens
+func getLargeTestDistribution(earners int) *distribution.Distribution {
+	d := distribution.NewDistribution()
+	tokens := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	for i := 0; i < earners; i++ {
+		earner := common.BigToAddress(big.NewInt(int64(i + 1)))
+		for j := 0; j <= i%len(tokens); j++ {
+			d.Set(earner, tokens[j], big.NewInt(int64(i*j+1)))
+		}
+	}
+	return d
+}
+
+func BenchmarkGenerateAllProofs(b *testing.B) {
+	d := getLargeTestDistribution(1000)
+	for i := 0; i < b.N; i++ {
+		// force the trees to be rebuilt for a fair comparison
+		d.Set(common.BigToAddress(big.NewInt(1)), common.HexToAddress("0x01"), big.NewInt(1))
+		if _, err := d.GenerateAllProofs(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateProofsPerEarner(b *testing.B) {
+	d := getLargeTestDistribution(1000)
+	cg := claimgen.NewClaimgen(d)
+	for i := 0; i < b.N; i++ {
+		d.Set(common.BigToAddress(big.NewInt(1)), common.HexToAddress("0x01"), big.NewInt(1))
+		for _, earner := range d.Earners() {
+			if _, _, err := cg.GenerateClaimProofForEarner(earner, d.TokensForEarner(earner), 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestEstimateProofBytes(t *testing.T) {
+	// the estimate is compared with the files written by WriteAllProofs in the claimgen tests
+	small, err := GetTestDistribution().EstimateProofBytes()
+	assert.NoError(t, err)
+	large, err := getLargeTestDistribution(300).EstimateProofBytes()
+	assert.NoError(t, err)
+	assert.Greater(t, large, small)
+
+	_, err = distribution.NewDistribution().EstimateProofBytes()
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+}
+
+func TestEstimateProofBytesCalculationEndTimestamp(t *testing.T) {
+	d := GetTestDistribution()
+	d.Snapshot = 1716681600000
+	d.CalculationEndTimestamp = 1716768000000
+	estimate, err := d.EstimateProofBytes()
+	assert.NoError(t, err)
+
+	// both omitempty fields are encoded, and the encoder ends each claim with a newline
+	cg := claimgen.NewClaimgen(d)
+	actual := int64(0)
+	for _, earner := range d.Earners() {
+		claim, err := cg.GenerateClaimProof(earner, d.TokensForEarner(earner), 3)
+		assert.NoError(t, err)
+		data, err := json.Marshal(claim)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"calculationEndTimestamp":1716768000000`)
+		actual += int64(len(data)) + 1
+	}
+	assert.Equal(t, actual, estimate)
+}
+
+func TestGenerateProofsForRequested(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+	all := distribution.NewDistribution()
+	err := all.LoadLinesForSnapshot(lines, 1716681600000)
+	assert.NoError(t, err)
+	earners := all.Earners()
+	requested := map[common.Address]bool{earners[3]: true, earners[len(earners)-1]: true, earners[7]: false}
+
+	// the lines must be from a single snapshot, like with LoadLines
+	d := distribution.NewDistribution()
+	matching := make([]*distribution.EarnerLine, 0, len(lines))
+	for _, line := range lines {
+		if line.Snapshot == 1716681600000 {
+			matching = append(matching, line)
+		}
+	}
+	err = d.LoadLinesForEarners(matching, requested)
+	assert.NoError(t, err)
+	assert.Equal(t, all.Len(), d.Len())
+
+	proofs, err := d.GenerateProofsForRequested()
+	assert.NoError(t, err)
+	assert.Len(t, proofs, 2)
+
+	// the proofs are the ones of the complete trees
+	allProofs, err := all.GenerateAllProofs()
+	assert.NoError(t, err)
+	for _, earner := range []common.Address{earners[3], earners[len(earners)-1]} {
+		assert.Equal(t, allProofs[earner], proofs[earner])
+	}
+
+	// requesting an earner without lines is an error
+	err = d.LoadLinesForEarners(nil, map[common.Address]bool{common.HexToAddress("0x01"): true})
+	assert.NoError(t, err)
+	_, err = d.GenerateProofsForRequested()
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+}
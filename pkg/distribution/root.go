@@ -0,0 +1,95 @@
+package distribution
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/wealdtech/go-merkletree/v2"
+)
+
+// VerifyDistributionRoot loads the lines into a new distribution, computes its root and compares it with
+// the expected root, so a published root can be audited from the raw lines in one call. On a mismatch
+// ErrRootMismatch is returned along with the computed root.
+func VerifyDistributionRoot(lines []*EarnerLine, expectedRoot []byte) (bool, error) {
+	distro := NewDistribution()
+	if err := distro.LoadLines(lines); err != nil {
+		return false, err
+	}
+	root, err := distro.ComputeRoot()
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(root, expectedRoot) {
+		return false, fmt.Errorf("%w - computed: 0x%x, expected: 0x%x", ErrRootMismatch, root, expectedRoot)
+	}
+	return true, nil
+}
+
+// ComputeRoot returns the same root as Merklize without building the trees, for any Arity. Only the hashes of
+// the level being reduced are kept, so memory usage is a single hash per earner rather than every
+// node of every tree. The distribution is not merklized afterwards.
+// Like Merklize, ErrEmptyDistribution is returned for an empty distribution.
+func (d *Distribution) ComputeRoot() ([]byte, error) {
+	if d.isMerklized() {
+		return d.accountTree.Root(), nil
+	}
+
+	if d.data.Len() == 0 {
+		return nil, ErrEmptyDistribution
+	}
+	format, err := d.leafFormat()
+	if err != nil {
+		return nil, err
+	}
+	arity, err := d.arity()
+	if err != nil {
+		return nil, err
+	}
+	hashType := d.treeHashType()
+	if err := format.checkDomains(hashType.HashLength(), arity); err != nil {
+		return nil, err
+	}
+	accountHashes := make([][]byte, 0, d.data.Len())
+	tokenHashes := make([][]byte, 0)
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		address := accountPair.Key
+		tokenHashes = tokenHashes[:0]
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			amount, err := amountBytes32(address, tokenPair.Key, tokenPair.Value)
+			if err != nil {
+				return nil, err
+			}
+			tokenHashes = append(tokenHashes, hashType.Hash(format.encodeTokenLeafBytes32(tokenPair.Key, amount)))
+		}
+
+		tokenRoot, err := reduceRoot(tokenHashes, hashType, arity)
+		if err != nil {
+			return nil, fmt.Errorf("%w - earner: %s", err, address.Hex())
+		}
+		accountHashes = append(accountHashes, hashType.Hash(format.encodeAccountLeaf(address, tokenRoot)))
+	}
+	return reduceRoot(accountHashes, hashType, arity)
+}
+
+// reduceRoot computes the root from hashed leaves the same way as the merkletree package, padding
+// with zero leaves to a power of the arity. The hashes are overwritten level by level.
+func reduceRoot(hashes [][]byte, hashType merkletree.HashType, arity int) ([]byte, error) {
+	if len(hashes) == 0 {
+		return nil, ErrEmptyTree
+	}
+	width := 1
+	for width < len(hashes) {
+		width *= arity
+	}
+	zero := make([]byte, hashType.HashLength())
+	for len(hashes) < width {
+		hashes = append(hashes, zero)
+	}
+
+	for n := len(hashes); n > 1; n /= arity {
+		for i := 0; i < n/arity; i++ {
+			hashes[i] = hashType.Hash(hashes[i*arity : (i+1)*arity]...)
+		}
+	}
+	return hashes[0], nil
+}
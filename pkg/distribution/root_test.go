@@ -0,0 +1,106 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeRoot(t *testing.T) {
+	d := GetTestDistribution()
+	root, err := d.ComputeRoot()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+
+	// computing the root does not merklize the distribution
+	_, found := d.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+
+	accountTree, _, err := d.Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, accountTree.Root(), root)
+
+	// the roots of the full fixture, completely filled trees and trees built with another hasher match too
+	snapshot := distribution.NewDistribution()
+	err = snapshot.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	for _, d := range []*distribution.Distribution{snapshot, GetCompleteTestDistribution(), getTestDistributionWithOptions(distribution.WithHasher(sha256Hasher{}))} {
+		root, err := d.ComputeRoot()
+		assert.NoError(t, err)
+		expected, err := d.Root()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, root)
+	}
+}
+
+func TestComputeRootErrors(t *testing.T) {
+	_, err := distribution.NewDistribution().ComputeRoot()
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+
+	d := GetTestDistribution()
+	amount, _ := d.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	amount.Lsh(amount, 256)
+	_, err = d.ComputeRoot()
+	assert.ErrorIs(t, err, distribution.ErrAmountOverflow)
+}
+
+// invalidateLargeTestDistribution resets the last amount of a distribution from getLargeTestDistribution
+// so it has to be merklized again
+func invalidateLargeTestDistribution(d *distribution.Distribution, earners int) {
+	_ = d.Set(common.BigToAddress(big.NewInt(int64(earners))), common.HexToAddress("0x01"), big.NewInt(1))
+}
+
+func BenchmarkComputeRoot(b *testing.B) {
+	d := getLargeTestDistribution(10_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		invalidateLargeTestDistribution(d, 10_000)
+		_, _ = d.ComputeRoot()
+	}
+}
+
+func BenchmarkMerklizeRoot(b *testing.B) {
+	d := getLargeTestDistribution(10_000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		invalidateLargeTestDistribution(d, 10_000)
+		_, _ = d.Root()
+	}
+}
+
+func TestVerifyDistributionRoot(t *testing.T) {
+	lines := make([]*distribution.EarnerLine, 0)
+	for _, line := range parseTestEarnerLines(t, getFullTestEarnerLines()) {
+		if line.Snapshot == 1716681600000 {
+			lines = append(lines, line)
+		}
+	}
+	expected, err := hex.DecodeString(snapshotDistributionRoot)
+	assert.NoError(t, err)
+
+	valid, err := distribution.VerifyDistributionRoot(lines, expected)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	wrong := common.CopyBytes(expected)
+	wrong[31] ^= 0x01
+	valid, err = distribution.VerifyDistributionRoot(lines, wrong)
+	assert.ErrorIs(t, err, distribution.ErrRootMismatch)
+	assert.ErrorContains(t, err, "computed: 0x"+snapshotDistributionRoot)
+	assert.False(t, valid)
+
+	// lines that cannot be loaded are reported as such
+	valid, err = distribution.VerifyDistributionRoot(parseTestEarnerLines(t, getFullTestEarnerLines()), expected)
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+	assert.False(t, valid)
+	valid, err = distribution.VerifyDistributionRoot(nil, expected)
+	assert.ErrorIs(t, err, distribution.ErrEmptyDistribution)
+	assert.False(t, valid)
+}
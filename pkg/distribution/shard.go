@@ -0,0 +1,58 @@
+package distribution
+
+import (
+	"fmt"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/wealdtech/go-merkletree/v2"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+)
+
+// ShardByEarner merklizes the distribution if needed and splits its earners into n contiguous
+// shards whose sizes differ by at most one.
+//
+// Each shard only holds the amounts and token trees of its own earners, but shares the full
+// account tree and keeps the earners' indices in it, so AccountProof, Root and GenerateAllProofs
+// on a shard produce proofs against the root of the whole distribution. Mutating a shard clears
+// its trees, after which it merklizes to a root of its own.
+func (d *Distribution) ShardByEarner(n int) ([]*Distribution, error) {
+	if n < 1 || n > d.data.Len() {
+		return nil, fmt.Errorf("%w - shards: %d, earners: %d", ErrInvalidShardCount, n, d.data.Len())
+	}
+	accountTree, tokenTrees, err := d.Merklize()
+	if err != nil {
+		return nil, err
+	}
+
+	shards := make([]*Distribution, 0, n)
+	accountPair := d.data.Oldest()
+	accountIndex := uint64(0)
+	for i := 0; i < n; i++ {
+		size := d.data.Len() / n
+		if i < d.data.Len()%n {
+			size++
+		}
+
+		shard := d.newEmpty()
+		shard.accountIndices = make(map[gethcommon.Address]uint64, size)
+		shard.tokenIndices = make(map[gethcommon.Address]map[gethcommon.Address]uint64, size)
+		shard.tokenTrees = make(map[gethcommon.Address]*merkletree.MerkleTree, size)
+		for j := 0; j < size; j++ {
+			earner := accountPair.Key
+			tokens := orderedmap.New[gethcommon.Address, *BigInt](accountPair.Value.Len())
+			for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+				tokens.Set(tokenPair.Key, tokenPair.Value.clone())
+			}
+			shard.data.Set(earner, tokens)
+
+			shard.accountIndices[earner] = accountIndex
+			shard.tokenIndices[earner] = d.tokenIndices[earner]
+			shard.tokenTrees[earner] = tokenTrees[earner]
+			accountPair = accountPair.Next()
+			accountIndex++
+		}
+		shard.accountTree = accountTree
+		shards = append(shards, shard)
+	}
+	return shards, nil
+}
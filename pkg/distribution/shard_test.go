@@ -0,0 +1,76 @@
+package distribution_test
+
+import (
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestShardByEarner(t *testing.T) {
+	d := getLargeTestDistribution(11)
+	root, err := d.Root()
+	assert.NoError(t, err)
+
+	shards, err := d.ShardByEarner(3)
+	assert.NoError(t, err)
+	assert.Len(t, shards, 3)
+
+	earners := make([]int, 0)
+	covered := 0
+	for _, shard := range shards {
+		earners = append(earners, shard.EarnerCount())
+		covered += shard.Len()
+
+		shardRoot, err := shard.Root()
+		assert.NoError(t, err)
+		assert.Equal(t, root, shardRoot)
+
+		proofs, err := shard.GenerateAllProofs()
+		assert.NoError(t, err)
+		assert.Len(t, proofs, shard.EarnerCount())
+
+		for _, earner := range shard.Earners() {
+			earnerTreeProof, earnerIndex, err := shard.AccountProof(earner)
+			assert.NoError(t, err)
+			expectedIndex, _ := d.GetAccountIndex(earner)
+			assert.Equal(t, expectedIndex, earnerIndex)
+			assert.Equal(t, earnerIndex, proofs[earner].EarnerIndex)
+
+			earnerLeaf, found := shard.AccountLeaf(earner)
+			assert.True(t, found)
+			verified, err := merkletree.VerifyProofUsing(earnerLeaf, false, &merkletree.Proof{Hashes: earnerTreeProof, Index: earnerIndex}, [][]byte{root}, keccak256.New())
+			assert.NoError(t, err)
+			assert.True(t, verified)
+		}
+	}
+	assert.Equal(t, []int{4, 4, 3}, earners)
+	assert.Equal(t, d.Len(), covered)
+	assert.Equal(t, d.Earners()[4], shards[1].Earners()[0])
+
+	// mutating a shard does not affect the distribution
+	earner := shards[0].Earners()[0]
+	err = shards[0].Set(earner, shards[0].TokensForEarner(earner)[0], nil)
+	assert.NoError(t, err)
+	_, found := d.GetAccountIndex(earner)
+	assert.True(t, found)
+	shardRoot, err := shards[0].Root()
+	assert.NoError(t, err)
+	assert.NotEqual(t, root, shardRoot)
+}
+
+func TestShardByEarnerInvalidCount(t *testing.T) {
+	d := getLargeTestDistribution(3)
+	for _, n := range []int{0, -1, 4} {
+		_, err := d.ShardByEarner(n)
+		assert.ErrorIs(t, err, distribution.ErrInvalidShardCount)
+	}
+
+	shards, err := d.ShardByEarner(3)
+	assert.NoError(t, err)
+	for _, shard := range shards {
+		assert.Equal(t, 1, shard.EarnerCount())
+	}
+}
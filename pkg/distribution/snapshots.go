@@ -0,0 +1,107 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// SnapshotDistribution holds one distribution per snapshot, mirroring how cumulative claims are
+// made on-chain against the most recent root.
+type SnapshotDistribution struct {
+	snapshots     []uint64 // sorted ascending
+	distributions map[uint64]*Distribution
+}
+
+func NewSnapshotDistribution() *SnapshotDistribution {
+	return &SnapshotDistribution{
+		distributions: make(map[uint64]*Distribution),
+	}
+}
+
+// NewSnapshotDistributionFromLines groups the lines by snapshot and loads each group into its own distribution.
+func NewSnapshotDistributionFromLines(lines []*EarnerLine) (*SnapshotDistribution, error) {
+	grouped := make(map[uint64][]*EarnerLine)
+	for _, line := range lines {
+		grouped[line.Snapshot] = append(grouped[line.Snapshot], line)
+	}
+
+	sd := NewSnapshotDistribution()
+	for snapshot, snapshotLines := range grouped {
+		distro := NewDistribution()
+		if err := distro.LoadLines(snapshotLines); err != nil {
+			return nil, fmt.Errorf("failed to load snapshot %d: %w", snapshot, err)
+		}
+		if err := sd.Add(snapshot, distro); err != nil {
+			return nil, err
+		}
+	}
+	return sd, nil
+}
+
+// BuildRootsPerSnapshot groups lines holding several snapshots like NewSnapshotDistributionFromLines and
+// returns the account root of each snapshot, so a combined file only has to be read once.
+func BuildRootsPerSnapshot(lines []*EarnerLine) (map[uint64][]byte, error) {
+	sd, err := NewSnapshotDistributionFromLines(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := make(map[uint64][]byte, len(sd.snapshots))
+	for _, snapshot := range sd.snapshots {
+		root, err := sd.distributions[snapshot].Root()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build the root of snapshot %d: %w", snapshot, err)
+		}
+		roots[snapshot] = root
+	}
+	return roots, nil
+}
+
+// Add adds the distribution for a snapshot, each snapshot may only be added once.
+func (sd *SnapshotDistribution) Add(snapshot uint64, d *Distribution) error {
+	if _, found := sd.distributions[snapshot]; found {
+		return fmt.Errorf("%w: %d", ErrDuplicateSnapshot, snapshot)
+	}
+	sd.distributions[snapshot] = d
+
+	i := sort.Search(len(sd.snapshots), func(i int) bool { return sd.snapshots[i] > snapshot })
+	sd.snapshots = append(sd.snapshots, 0)
+	copy(sd.snapshots[i+1:], sd.snapshots[i:])
+	sd.snapshots[i] = snapshot
+	return nil
+}
+
+// Snapshots returns the snapshots in ascending order.
+func (sd *SnapshotDistribution) Snapshots() []uint64 {
+	return append([]uint64(nil), sd.snapshots...)
+}
+
+// Get returns the distribution of a snapshot and whether it was added
+func (sd *SnapshotDistribution) Get(snapshot uint64) (*Distribution, bool) {
+	d, found := sd.distributions[snapshot]
+	return d, found
+}
+
+// DistributionAt returns the most recent distribution whose snapshot does not exceed the given one,
+// along with that snapshot. It returns false if every snapshot is later.
+func (sd *SnapshotDistribution) DistributionAt(snapshot uint64) (*Distribution, uint64, bool) {
+	i := sort.Search(len(sd.snapshots), func(i int) bool { return sd.snapshots[i] > snapshot })
+	if i == 0 {
+		return nil, 0, false
+	}
+	return sd.distributions[sd.snapshots[i-1]], sd.snapshots[i-1], true
+}
+
+// CumulativeAt returns the cumulative amount of an earner/token pair in the most recent snapshot not
+// exceeding the given one, and whether the pair is in that snapshot. Earlier snapshots are not
+// consulted, since on-chain claims are only made against the latest root.
+func (sd *SnapshotDistribution) CumulativeAt(earner, token gethcommon.Address, snapshot uint64) (*big.Int, bool) {
+	d, _, found := sd.DistributionAt(snapshot)
+	if !found {
+		return big.NewInt(0), false
+	}
+	return d.Get(earner, token)
+}
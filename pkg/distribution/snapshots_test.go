@@ -0,0 +1,90 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotDistributionCumulativeAt(t *testing.T) {
+	sd, err := distribution.NewSnapshotDistributionFromLines(parseTestEarnerLines(t, getFullTestEarnerLines()))
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{1712102400000, 1716422400000, 1716681600000}, sd.Snapshots())
+
+	earner := gethcommon.HexToAddress("0x3c42cd72639e3e8d11ab8d0072cc13bd5d8aa83c")
+	token := gethcommon.HexToAddress("0x94373a4919b3240d86ea41593d5eba789fef3848")
+
+	// before the first snapshot
+	_, found := sd.CumulativeAt(earner, token, 1712102399999)
+	assert.False(t, found)
+
+	expected, _ := new(big.Int).SetString("8462065100158564", 10)
+	for _, snapshot := range []uint64{1712102400000, 1716422399999} {
+		amount, found := sd.CumulativeAt(earner, token, snapshot)
+		assert.True(t, found)
+		assert.Equal(t, expected, amount)
+	}
+
+	// the earner is not in the later snapshots
+	_, found = sd.CumulativeAt(earner, token, 1716681600000)
+	assert.False(t, found)
+
+	earner = gethcommon.HexToAddress("0x93feb4ef15c70e3fdf05aacde3e546553d063a5a")
+	for _, snapshot := range []uint64{1716681600000, 1800000000000} {
+		amount, found := sd.CumulativeAt(earner, token, snapshot)
+		assert.True(t, found)
+		assert.Equal(t, big.NewInt(36169082275000), amount)
+	}
+	_, found = sd.CumulativeAt(earner, token, 1716681599999)
+	assert.False(t, found)
+
+	d, snapshot, found := sd.DistributionAt(1716681599999)
+	assert.True(t, found)
+	assert.Equal(t, uint64(1716422400000), snapshot)
+	assert.Len(t, d.Earners(), 2)
+}
+
+func TestSnapshotDistributionAdd(t *testing.T) {
+	sd := distribution.NewSnapshotDistribution()
+	assert.NoError(t, sd.Add(1716681600000, GetCompleteTestDistribution()))
+	assert.NoError(t, sd.Add(1712102400000, GetTestDistribution()))
+
+	err := sd.Add(1712102400000, GetTestDistribution())
+	assert.ErrorIs(t, err, distribution.ErrDuplicateSnapshot)
+
+	assert.Equal(t, []uint64{1712102400000, 1716681600000}, sd.Snapshots())
+	d, found := sd.Get(1716681600000)
+	assert.True(t, found)
+	assert.True(t, d.Equal(GetCompleteTestDistribution()))
+}
+
+func TestBuildRootsPerSnapshot(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+	roots, err := distribution.BuildRootsPerSnapshot(lines)
+	assert.NoError(t, err)
+	assert.Len(t, roots, 3)
+
+	distinct := make(map[string]bool)
+	for snapshot, root := range roots {
+		distinct[hex.EncodeToString(root)] = true
+		assert.Equal(t, snapshotRoots[snapshot], hex.EncodeToString(root), "snapshot %d", snapshot)
+
+		d := distribution.NewDistribution()
+		err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), snapshot)
+		assert.NoError(t, err)
+		expected, err := d.Root()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, root, "snapshot %d", snapshot)
+	}
+	assert.Len(t, distinct, 3)
+
+	_, err = distribution.BuildRootsPerSnapshot(nil)
+	assert.NoError(t, err)
+	lines = append(lines, &distribution.EarnerLine{Earner: lines[0].Earner, Token: lines[0].Token, Snapshot: lines[0].Snapshot, CumulativeAmount: "-1"})
+	_, err = distribution.BuildRootsPerSnapshot(lines)
+	assert.ErrorIs(t, err, distribution.ErrNegativeAmount)
+}
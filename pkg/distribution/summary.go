@@ -0,0 +1,77 @@
+package distribution
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/holiman/uint256"
+)
+
+// TokenTotals returns the sum of every earner's amount for each token.
+// The returned amounts are fresh values that do not alias the distribution.
+func (d *Distribution) TokenTotals() map[gethcommon.Address]*big.Int {
+	totals := make(map[gethcommon.Address]*big.Int)
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			total, found := totals[tokenPair.Key]
+			if !found {
+				total = new(big.Int)
+				totals[tokenPair.Key] = total
+			}
+			total.Add(total, amountOrZero(tokenPair.Value))
+		}
+	}
+	return totals
+}
+
+// AssertTokenCaps checks the total of every token against its funded cap before a root is submitted,
+// returning ErrCapExceeded for each token whose total exceeds its cap, joined in token order, along with
+// the total, the cap and the overage. Tokens without a cap are skipped and a nil cap is treated as zero.
+func (d *Distribution) AssertTokenCaps(caps map[gethcommon.Address]*big.Int) error {
+	totals := d.TokenTotals()
+	tokens := make([]gethcommon.Address, 0, len(totals))
+	for token := range totals {
+		tokens = append(tokens, token)
+	}
+	sortAddresses(tokens)
+
+	var errs []error
+	for _, token := range tokens {
+		tokenCap, found := caps[token]
+		if !found {
+			continue
+		}
+		if tokenCap == nil {
+			tokenCap = new(big.Int)
+		}
+		if total := totals[token]; total.Cmp(tokenCap) > 0 {
+			errs = append(errs, fmt.Errorf("%w - token: %s, total: %s, cap: %s, overage: %s",
+				ErrCapExceeded, token.Hex(), total.String(), tokenCap.String(), new(big.Int).Sub(total, tokenCap).String()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// InputHash returns a keccak256 fingerprint of the earner/token/amount entries that does not depend
+// on the tree format. Each entry is encoded in order as (earner || token || amount), with the amount
+// as a 32 byte big endian integer like in the token leaves, so equal distributions hash equally and
+// nil amounts hash like zero.
+func (d *Distribution) InputHash() [32]byte {
+	hasher := crypto.NewKeccakState()
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			amount, _ := uint256.FromBig(amountOrZero(tokenPair.Value))
+			amountBytes := amount.Bytes32()
+			hasher.Write(accountPair.Key.Bytes())
+			hasher.Write(tokenPair.Key.Bytes())
+			hasher.Write(amountBytes[:])
+		}
+	}
+
+	var hash [32]byte
+	hasher.Read(hash[:])
+	return hash
+}
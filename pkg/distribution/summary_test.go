@@ -0,0 +1,82 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenTotals(t *testing.T) {
+	d := GetCompleteTestDistribution()
+
+	totals := d.TokenTotals()
+	assert.Len(t, totals, len(tests.TestTokens))
+
+	for j, token := range tests.TestTokens {
+		// every address holds j+i+2 of token j
+		expected := int64(0)
+		for i := range tests.TestAddresses {
+			expected += int64(j + i + 2)
+		}
+		assert.Equal(t, big.NewInt(expected), totals[token])
+	}
+
+	// mutating the totals does not touch the distribution
+	totals[tests.TestTokens[0]].SetInt64(0)
+	amount, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.Equal(t, big.NewInt(2), amount)
+}
+
+func TestInputHash(t *testing.T) {
+	d := GetTestDistribution()
+	assert.Equal(t, d.InputHash(), GetTestDistribution().InputHash())
+	assert.Equal(t, d.InputHash(), d.Clone().InputHash())
+	assert.NotEqual(t, d.InputHash(), GetCompleteTestDistribution().InputHash())
+
+	// the hash does not depend on the hasher used for the trees
+	assert.Equal(t, d.InputHash(), getTestDistributionWithOptions(distribution.WithHasher(sha256Hasher{})).InputHash())
+
+	// any changed amount changes the hash
+	changed := GetTestDistribution()
+	err := changed.Set(tests.TestAddresses[4], tests.TestTokens[0], big.NewInt(6))
+	assert.NoError(t, err)
+	assert.NotEqual(t, d.InputHash(), changed.InputHash())
+
+	// nil and zero amounts hash identically, and unlike a missing entry
+	zero := distribution.NewDistribution()
+	err = zero.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(0))
+	assert.NoError(t, err)
+	unset := distribution.NewDistribution()
+	err = unset.Set(tests.TestAddresses[0], tests.TestTokens[0], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, zero.InputHash(), unset.InputHash())
+	assert.NotEqual(t, zero.InputHash(), distribution.NewDistribution().InputHash())
+}
+
+func TestAssertTokenCaps(t *testing.T) {
+	// the totals of the first two tokens are 20 and 25
+	d := GetCompleteTestDistribution()
+
+	err := d.AssertTokenCaps(map[common.Address]*big.Int{
+		tests.TestTokens[0]: big.NewInt(19),
+		tests.TestTokens[1]: big.NewInt(100),
+	})
+	assert.ErrorIs(t, err, distribution.ErrCapExceeded)
+	assert.ErrorContains(t, err, tests.TestTokens[0].Hex())
+	assert.ErrorContains(t, err, "total: 20, cap: 19, overage: 1")
+	assert.NotContains(t, err.Error(), tests.TestTokens[1].Hex())
+
+	// a total equal to its cap is funded, and tokens without a cap are skipped
+	err = d.AssertTokenCaps(map[common.Address]*big.Int{tests.TestTokens[0]: big.NewInt(20)})
+	assert.NoError(t, err)
+	assert.NoError(t, d.AssertTokenCaps(nil))
+
+	// every token over its cap is listed
+	err = d.AssertTokenCaps(map[common.Address]*big.Int{tests.TestTokens[0]: big.NewInt(19), tests.TestTokens[1]: nil})
+	assert.ErrorContains(t, err, "overage: 1")
+	assert.ErrorContains(t, err, "total: 25, cap: 0, overage: 25")
+}
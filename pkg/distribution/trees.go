@@ -0,0 +1,371 @@
+package distribution
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/wealdtech/go-merkletree/v2"
+)
+
+// AccountTreeDepth returns the depth of the account tree, which is the number of sibling
+// hashes in an earner proof. The distribution must be merklized first.
+func (d *Distribution) AccountTreeDepth() (int, error) {
+	if !d.isMerklized() {
+		return 0, ErrNotMerklized
+	}
+	return treeDepth(d.accountTree), nil
+}
+
+// TokenTreeDepth returns the depth of the token tree of an earner, which is the number of
+// sibling hashes in each of its token proofs. The distribution must be merklized first.
+func (d *Distribution) TokenTreeDepth(earner gethcommon.Address) (int, error) {
+	if !d.isMerklized() {
+		return 0, ErrNotMerklized
+	}
+	tokenTree, found := d.tokenTrees[earner]
+	if !found {
+		return 0, fmt.Errorf("%w: %s", ErrEarnerNotFound, earner.Hex())
+	}
+	return treeDepth(tokenTree), nil
+}
+
+// AccountTreeNodes returns every node of the account tree level by level, leaves first and the root last,
+// so the whole tree can be ingested by a verifier that does not use this library. The distribution must
+// be merklized first.
+//
+// The first level is the hashed account leaves in account index order, padded with all zero nodes up to
+// the next power of two as described on Merklize, and every following level is half as wide, with node i
+// being the hash of nodes 2i and 2i+1 of the level below. A tree of width leaves has 2*width-1 nodes: a
+// level starts at 2*width-2*(width>>level) and the last node is the root. The sibling of node i is
+// node i^1 of the same level, which is how the proofs of AccountProof are read. A single earner's tree is
+// its hashed leaf alone. The nodes are copies and may be modified.
+func (d *Distribution) AccountTreeNodes() ([][]byte, error) {
+	if !d.isMerklized() {
+		return nil, ErrNotMerklized
+	}
+	nodes := make([][]byte, 0, len(d.accountTree.Nodes)-1)
+	for width := len(d.accountTree.Nodes) / 2; width >= 1; width /= 2 {
+		for _, node := range d.accountTree.Nodes[width : 2*width] {
+			nodes = append(nodes, copyBytes(node))
+		}
+	}
+	return nodes, nil
+}
+
+// treeDepth returns ceil(log2(leafCount)), trees are padded to a power of two leaves
+func treeDepth(tree *merkletree.MerkleTree) int {
+	return bits.Len(uint(len(tree.Data) - 1))
+}
+
+// MarshalTrees encodes the merklized account tree, token trees and indices so they can be
+// restored with LoadTrees instead of merklizing again. The distribution must be merklized first.
+//
+// The encoding is a sequence of big endian uint32 length prefixed values: the account tree
+// followed by, for each earner in account index order, its address, its token addresses in
+// token index order and its token tree. A tree is its data leaves followed by its nodes.
+func (d *Distribution) MarshalTrees() ([]byte, error) {
+	if !d.isMerklized() {
+		return nil, ErrNotMerklized
+	}
+
+	buf := new(bytes.Buffer)
+	writeTree(buf, d.accountTree)
+	writeUint32(buf, uint32(d.data.Len()))
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		writeBytes(buf, accountPair.Key.Bytes())
+		writeUint32(buf, uint32(accountPair.Value.Len()))
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			writeBytes(buf, tokenPair.Key.Bytes())
+		}
+		writeTree(buf, d.tokenTrees[accountPair.Key])
+	}
+	return buf.Bytes(), nil
+}
+
+// LoadTrees restores trees encoded by MarshalTrees, so the distribution can be proven without
+// encoding its leaves again. The distribution must hold the same data the trees were built from,
+// which is checked by comparing the encoded leaves, and every node is rehashed from the leaves so a
+// corrupted or stale file is rejected with ErrTreesMismatch rather than giving a wrong root.
+func (d *Distribution) LoadTrees(p []byte) error {
+	format, err := d.leafFormat()
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(p)
+
+	accountTree, err := readTree(r, d.treeHashType())
+	if err != nil {
+		return err
+	}
+	earnerCount, err := readUint32(r)
+	if err != nil {
+		return err
+	}
+	if int(earnerCount) != d.data.Len() || len(accountTree.Data) != d.data.Len() {
+		return fmt.Errorf("%w - earners: %d, expected: %d", ErrTreesMismatch, earnerCount, d.data.Len())
+	}
+
+	accountIndices := make(map[gethcommon.Address]uint64, earnerCount)
+	tokenIndices := make(map[gethcommon.Address]map[gethcommon.Address]uint64, earnerCount)
+	tokenTrees := make(map[gethcommon.Address]*merkletree.MerkleTree, earnerCount)
+	accountPair := d.data.Oldest()
+	for accountIndex := uint64(0); accountIndex < uint64(earnerCount); accountIndex++ {
+		earner, err := readAddress(r)
+		if err != nil {
+			return err
+		}
+		if earner != accountPair.Key {
+			return fmt.Errorf("%w - earner: %s, expected: %s", ErrTreesMismatch, earner.Hex(), accountPair.Key.Hex())
+		}
+		tokenCount, err := readUint32(r)
+		if err != nil {
+			return err
+		}
+		if int(tokenCount) != accountPair.Value.Len() {
+			return fmt.Errorf("%w - earner: %s, tokens: %d, expected: %d", ErrTreesMismatch, earner.Hex(), tokenCount, accountPair.Value.Len())
+		}
+
+		tokens := make([]gethcommon.Address, tokenCount)
+		for i := range tokens {
+			if tokens[i], err = readAddress(r); err != nil {
+				return err
+			}
+		}
+		tokenTree, err := readTree(r, d.treeHashType())
+		if err != nil {
+			return err
+		}
+		if len(tokenTree.Data) != len(tokens) {
+			return fmt.Errorf("%w - earner: %s, token leaves: %d, expected: %d", ErrTreesMismatch, earner.Hex(), len(tokenTree.Data), len(tokens))
+		}
+
+		indices := make(map[gethcommon.Address]uint64, len(tokens))
+		for tokenIndex, token := range tokens {
+			amount, found := accountPair.Value.Get(token)
+			if !found || !bytes.Equal(tokenTree.Data[tokenIndex], format.encodeTokenLeaf(token, amountOrZero(amount))) {
+				return fmt.Errorf("%w - earner: %s, token: %s", ErrTreesMismatch, earner.Hex(), token.Hex())
+			}
+			indices[token] = uint64(tokenIndex)
+		}
+		if !bytes.Equal(accountTree.Data[accountIndex], format.encodeAccountLeaf(earner, tokenTree.Root())) {
+			return fmt.Errorf("%w - earner: %s", ErrTreesMismatch, earner.Hex())
+		}
+		accountIndices[earner] = accountIndex
+		tokenIndices[earner] = indices
+		tokenTrees[earner] = tokenTree
+		accountPair = accountPair.Next()
+	}
+
+	if r.Len() != 0 {
+		return fmt.Errorf("%w - %d trailing bytes", ErrTreesMismatch, r.Len())
+	}
+
+	d.accountIndices = accountIndices
+	d.tokenIndices = tokenIndices
+	d.accountTree = accountTree
+	d.tokenTrees = tokenTrees
+	return nil
+}
+
+func writeUint32(buf *bytes.Buffer, v uint32) {
+	_ = binary.Write(buf, binary.BigEndian, v)
+}
+
+func writeBytes(buf *bytes.Buffer, b []byte) {
+	writeUint32(buf, uint32(len(b)))
+	buf.Write(b)
+}
+
+func writeTree(buf *bytes.Buffer, tree *merkletree.MerkleTree) {
+	writeUint32(buf, uint32(len(tree.Data)))
+	for _, data := range tree.Data {
+		writeBytes(buf, data)
+	}
+	writeUint32(buf, uint32(len(tree.Nodes)))
+	for _, node := range tree.Nodes {
+		writeBytes(buf, node)
+	}
+}
+
+func readUint32(r *bytes.Reader) (uint32, error) {
+	var v uint32
+	if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+		return 0, fmt.Errorf("failed to read trees: %w", err)
+	}
+	return v, nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	length, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(length) > int64(r.Len()) {
+		return nil, fmt.Errorf("failed to read trees: %w", io.ErrUnexpectedEOF)
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(r, b)
+	return b, err
+}
+
+func readAddress(r *bytes.Reader) (gethcommon.Address, error) {
+	b, err := readBytes(r)
+	if err != nil {
+		return gethcommon.Address{}, err
+	}
+	if len(b) != gethcommon.AddressLength {
+		return gethcommon.Address{}, fmt.Errorf("failed to read trees: invalid address length %d", len(b))
+	}
+	return gethcommon.BytesToAddress(b), nil
+}
+
+// checkTreeNodes rehashes the leaves and branches of a tree read by readTree, returning ErrTreesMismatch
+// for the first node that differs from the one Merklize would have built
+func checkTreeNodes(data, nodes [][]byte, hashType merkletree.HashType) error {
+	width := len(nodes) / 2
+	zero := make([]byte, hashType.HashLength())
+	for i := 0; i < width; i++ {
+		expected := zero
+		if i < len(data) {
+			expected = hashType.Hash(data[i])
+		}
+		if !bytes.Equal(nodes[width+i], expected) {
+			return fmt.Errorf("%w - leaf node: %d", ErrTreesMismatch, i)
+		}
+	}
+	for i := width - 1; i >= 1; i-- {
+		if !bytes.Equal(nodes[i], hashType.Hash(nodes[2*i], nodes[2*i+1])) {
+			return fmt.Errorf("%w - branch node: %d", ErrTreesMismatch, i)
+		}
+	}
+	return nil
+}
+
+func readTree(r *bytes.Reader, hashType merkletree.HashType) (*merkletree.MerkleTree, error) {
+	dataCount, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([][]byte, 0, min(int(dataCount), r.Len()))
+	for i := uint32(0); i < dataCount; i++ {
+		leaf, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, leaf)
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("failed to read trees: %w", ErrEmptyTree)
+	}
+	nodeCount, err := readUint32(r)
+	if err != nil {
+		return nil, err
+	}
+	// the nodes hold the leaves padded to a power of two and the branches above them
+	width := 1
+	for width < len(data) {
+		width *= 2
+	}
+	if int(nodeCount) != 2*width {
+		return nil, fmt.Errorf("failed to read trees: invalid node count %d for %d leaves", nodeCount, len(data))
+	}
+	nodes := make([][]byte, 0, min(int(nodeCount), r.Len()))
+	for i := uint32(0); i < nodeCount; i++ {
+		node, err := readBytes(r)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if err := checkTreeNodes(data, nodes, hashType); err != nil {
+		return nil, err
+	}
+
+	return &merkletree.MerkleTree{
+		Hash:  hashType,
+		Data:  data,
+		Nodes: nodes,
+	}, nil
+}
+
+// EntryByIndex returns the earner, token and amount at the given account and token indices,
+// the inverse of GetAccountIndex and GetTokenIndex. The distribution must be merklized first.
+func (d *Distribution) EntryByIndex(accountIndex, tokenIndex uint64) (gethcommon.Address, gethcommon.Address, *big.Int, bool) {
+	if !d.isMerklized() || accountIndex >= uint64(d.data.Len()) {
+		return gethcommon.Address{}, gethcommon.Address{}, nil, false
+	}
+
+	// the account tree leaves are indexed in the same order as the data
+	earner := gethcommon.BytesToAddress(d.accountTree.Data[accountIndex][1 : 1+gethcommon.AddressLength])
+	allocatedTokens, found := d.data.Get(earner)
+	if !found || tokenIndex >= uint64(allocatedTokens.Len()) {
+		return gethcommon.Address{}, gethcommon.Address{}, nil, false
+	}
+
+	tokenPair := allocatedTokens.Oldest()
+	for i := uint64(0); i < tokenIndex; i++ {
+		tokenPair = tokenPair.Next()
+	}
+	return earner, tokenPair.Key, amountOrZero(tokenPair.Value), true
+}
+
+// TokenLeaf returns the encoded token leaf of an earner and token, and whether the pair is present.
+func (d *Distribution) TokenLeaf(earner, token gethcommon.Address) ([]byte, bool) {
+	allocatedTokens, found := d.data.Get(earner)
+	if !found {
+		return nil, false
+	}
+	amount, found := allocatedTokens.Get(token)
+	if !found {
+		return nil, false
+	}
+	format, err := d.leafFormat()
+	if err != nil {
+		return nil, false
+	}
+	return format.encodeTokenLeaf(token, amountOrZero(amount)), true
+}
+
+// AccountLeaf returns the encoded account leaf of an earner, and whether the earner is present.
+// The leaf commits to the earner's token root, so the distribution must be merklized first.
+func (d *Distribution) AccountLeaf(earner gethcommon.Address) ([]byte, bool) {
+	if !d.isMerklized() {
+		return nil, false
+	}
+	tokenTree, found := d.tokenTrees[earner]
+	if !found {
+		return nil, false
+	}
+	format, err := d.leafFormat()
+	if err != nil {
+		return nil, false
+	}
+	return format.encodeAccountLeaf(earner, tokenTree.Root()), true
+}
+
+// FindByTokenLeaf returns the first earner, in merklization order, with a token leaf equal to the
+// encoded leaf, along with the token and amount decoded from it. Only the earners holding the
+// leaf's token and amount are matched, so no leaves are encoded while scanning.
+func (d *Distribution) FindByTokenLeaf(leaf []byte) (gethcommon.Address, gethcommon.Address, *big.Int, bool) {
+	format, err := d.leafFormat()
+	if err != nil || len(leaf) != 1+gethcommon.AddressLength+32 || leaf[0] != format.tokenLeafSalt {
+		return gethcommon.Address{}, gethcommon.Address{}, nil, false
+	}
+	// (tokenLeafSalt || token || amount)
+	token := gethcommon.BytesToAddress(leaf[1:21])
+	amount := new(big.Int).SetBytes(leaf[21:53])
+
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		tokenAmount, found := accountPair.Value.Get(token)
+		if found && amountOrZero(tokenAmount).Cmp(amount) == 0 {
+			return accountPair.Key, token, amount, true
+		}
+	}
+	return gethcommon.Address{}, gethcommon.Address{}, nil, false
+}
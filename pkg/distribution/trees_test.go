@@ -0,0 +1,387 @@
+package distribution_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+	"github.com/wealdtech/go-merkletree/v2"
+	"github.com/wealdtech/go-merkletree/v2/keccak256"
+)
+
+func TestTreeDepth(t *testing.T) {
+	d := GetTestDistribution()
+
+	_, err := d.AccountTreeDepth()
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+	_, err = d.TokenTreeDepth(tests.TestAddresses[0])
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	// 5 earners
+	depth, err := d.AccountTreeDepth()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, depth)
+
+	proof, err := accountTree.GenerateProofWithIndex(0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, proof.Hashes, depth)
+
+	// earners hold 5, 4, 3, 2 and 1 tokens
+	expectedDepths := []int{3, 2, 2, 1, 0}
+	for i, earner := range tests.TestAddresses {
+		depth, err := d.TokenTreeDepth(earner)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedDepths[i], depth)
+
+		proof, err := tokenTrees[earner].GenerateProofWithIndex(0, 0)
+		assert.NoError(t, err)
+		assert.Len(t, proof.Hashes, depth)
+	}
+
+	_, err = d.TokenTreeDepth(common.Address{})
+	assert.ErrorIs(t, err, distribution.ErrEarnerNotFound)
+}
+
+func TestMarshalAndLoadTrees(t *testing.T) {
+	original := GetTestDistribution()
+
+	_, err := original.MarshalTrees()
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	root, err := original.Root()
+	assert.NoError(t, err)
+
+	data, err := original.MarshalTrees()
+	assert.NoError(t, err)
+
+	loaded := GetTestDistribution()
+	err = loaded.LoadTrees(data)
+	assert.NoError(t, err)
+
+	accountTree, tokenTrees, err := loaded.Merklize()
+	assert.NoError(t, err)
+	assert.Equal(t, root, accountTree.Root())
+
+	for i, earner := range tests.TestAddresses {
+		accountIndex, found := loaded.GetAccountIndex(earner)
+		assert.True(t, found)
+		assert.Equal(t, uint64(i), accountIndex)
+
+		accountProof, err := accountTree.GenerateProofWithIndex(accountIndex, 0)
+		assert.NoError(t, err)
+		verified, err := merkletree.VerifyProofUsing(accountTree.Data[accountIndex], false, accountProof, [][]byte{root}, keccak256.New())
+		assert.NoError(t, err)
+		assert.True(t, verified)
+
+		tokenTree := tokenTrees[earner]
+		for j, token := range loaded.TokensForEarner(earner) {
+			tokenIndex, found := loaded.GetTokenIndex(earner, token)
+			assert.True(t, found)
+			assert.Equal(t, uint64(j), tokenIndex)
+
+			tokenProof, err := tokenTree.GenerateProofWithIndex(tokenIndex, 0)
+			assert.NoError(t, err)
+			verified, err := merkletree.VerifyProofUsing(tokenTree.Data[tokenIndex], false, tokenProof, [][]byte{tokenTree.Root()}, keccak256.New())
+			assert.NoError(t, err)
+			assert.True(t, verified)
+		}
+	}
+}
+
+func TestLoadTreesMismatch(t *testing.T) {
+	original := GetTestDistribution()
+	_, _, err := original.Merklize()
+	assert.NoError(t, err)
+
+	data, err := original.MarshalTrees()
+	assert.NoError(t, err)
+
+	err = GetCompleteTestDistribution().LoadTrees(data)
+	assert.ErrorIs(t, err, distribution.ErrTreesMismatch)
+
+	loaded := GetTestDistribution()
+	err = loaded.LoadTrees(data[:len(data)-1])
+	assert.Error(t, err)
+	_, found := loaded.GetAccountIndex(tests.TestAddresses[0])
+	assert.False(t, found)
+}
+
+func TestLoadTreesCorruptedBranch(t *testing.T) {
+	original := GetTestDistribution()
+	accountTree, tokenTrees, err := original.Merklize()
+	assert.NoError(t, err)
+	data, err := original.MarshalTrees()
+	assert.NoError(t, err)
+
+	// flips a byte of the account root and then of the first token root, both are branch nodes, the token
+	// root is also in the account leaves so its last occurrence is the node
+	for _, root := range [][]byte{accountTree.Root(), tokenTrees[original.Earners()[0]].Root()} {
+		corrupted := bytes.Clone(data)
+		offset := bytes.LastIndex(corrupted, root)
+		assert.GreaterOrEqual(t, offset, 0)
+		corrupted[offset] ^= 0xff
+
+		loaded := GetTestDistribution()
+		err = loaded.LoadTrees(corrupted)
+		assert.ErrorIs(t, err, distribution.ErrTreesMismatch)
+		_, err = loaded.AccountTreeDepth()
+		assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+	}
+}
+
+func TestEntryByIndex(t *testing.T) {
+	d := GetTestDistribution()
+
+	_, _, _, found := d.EntryByIndex(0, 0)
+	assert.False(t, found)
+
+	_, _, err := d.Merklize()
+	assert.NoError(t, err)
+
+	for _, earner := range d.Earners() {
+		accountIndex, _ := d.GetAccountIndex(earner)
+		for _, token := range d.TokensForEarner(earner) {
+			tokenIndex, _ := d.GetTokenIndex(earner, token)
+
+			entryEarner, entryToken, amount, found := d.EntryByIndex(accountIndex, tokenIndex)
+			assert.True(t, found)
+			assert.Equal(t, earner, entryEarner)
+			assert.Equal(t, token, entryToken)
+
+			expected, _ := d.Get(earner, token)
+			assert.Equal(t, expected, amount)
+		}
+	}
+
+	// the last earner only holds a single token
+	_, _, _, found = d.EntryByIndex(uint64(len(tests.TestAddresses)-1), 1)
+	assert.False(t, found)
+	_, _, _, found = d.EntryByIndex(uint64(len(tests.TestAddresses)), 0)
+	assert.False(t, found)
+}
+
+func TestTokenLeaf(t *testing.T) {
+	d := GetTestDistribution()
+
+	leaf, found := d.TokenLeaf(tests.TestAddresses[1], tests.TestTokens[2])
+	assert.True(t, found)
+	assert.Equal(t, distribution.EncodeTokenLeaf(tests.TestTokens[2], big.NewInt(4)), leaf)
+
+	_, found = d.TokenLeaf(tests.TestAddresses[4], tests.TestTokens[1])
+	assert.False(t, found)
+	_, found = d.TokenLeaf(common.Address{}, tests.TestTokens[0])
+	assert.False(t, found)
+}
+
+func TestAccountLeaf(t *testing.T) {
+	d := GetTestDistribution()
+
+	_, found := d.AccountLeaf(tests.TestAddresses[0])
+	assert.False(t, found)
+
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	for i, earner := range tests.TestAddresses {
+		leaf, found := d.AccountLeaf(earner)
+		assert.True(t, found)
+		assert.Equal(t, distribution.EncodeAccountLeaf(earner, tokenTrees[earner].Root()), leaf)
+		assert.Equal(t, accountTree.Data[i], leaf)
+	}
+
+	_, found = d.AccountLeaf(common.Address{})
+	assert.False(t, found)
+}
+
+func TestFindByTokenLeaf(t *testing.T) {
+	d := GetTestDistribution()
+
+	// earner 2 is the only one holding 5 of token 2
+	leaf, found := d.TokenLeaf(tests.TestAddresses[2], tests.TestTokens[2])
+	assert.True(t, found)
+	earner, token, amount, found := d.FindByTokenLeaf(leaf)
+	assert.True(t, found)
+	assert.Equal(t, tests.TestAddresses[2], earner)
+	assert.Equal(t, tests.TestTokens[2], token)
+	assert.Equal(t, big.NewInt(5), amount)
+
+	// the first earner in order wins when leaves are equal
+	err := d.Set(tests.TestAddresses[3], tests.TestTokens[1], big.NewInt(2))
+	assert.NoError(t, err)
+	earner, _, _, found = d.FindByTokenLeaf(distribution.EncodeTokenLeaf(tests.TestTokens[1], big.NewInt(2)))
+	assert.True(t, found)
+	assert.Equal(t, tests.TestAddresses[0], earner)
+
+	_, _, _, found = d.FindByTokenLeaf(distribution.EncodeTokenLeaf(tests.TestTokens[1], big.NewInt(100)))
+	assert.False(t, found)
+	_, _, _, found = d.FindByTokenLeaf(leaf[:52])
+	assert.False(t, found)
+
+	// account leaves have a different salt
+	accountLeaf := append([]byte{}, leaf...)
+	accountLeaf[0] = distribution.EarnerLeafSalt
+	_, _, _, found = d.FindByTokenLeaf(accountLeaf)
+	assert.False(t, found)
+}
+
+// snapshotDistributionRoot is the account root of the 1716681600000 snapshot of the full test earner lines
+const snapshotDistributionRoot = "e7cb45fa147b425530b2a4ddb114f33beb354eb784bb92714026ab933d4ea048"
+
+// snapshotRoots are the account roots of each snapshot of the full test earner lines, loaded with
+// LoadLinesForSnapshot and merklized with the default keccak256 hasher and CurrentVersion leaves.
+// They were generated by the implementation when they were added and are checked against
+// referenceAccountRoot, so a change to the ordering, padding or salts of the trees changes them.
+var snapshotRoots = map[uint64]string{
+	// a single earner with a single token, the root is the hash of the only account leaf
+	1712102400000: "95aea2f5e687a4398a02dd1413d73cd563d12bd78af02839a8c727e5353dcab6",
+	// 2 earners with 6 tokens
+	1716422400000: "f7350938833f677e7350a3e93ee8a0d1f94e5a7655cc6add1c97411f3b080332",
+	// 240 earners with 596 tokens
+	1716681600000: snapshotDistributionRoot,
+}
+
+func TestSnapshotRootsGolden(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+	for snapshot, expected := range snapshotRoots {
+		d := distribution.NewDistribution()
+		err := d.LoadLinesForSnapshot(lines, snapshot)
+		assert.NoError(t, err)
+
+		accountTree, _, err := d.Merklize()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, hex.EncodeToString(accountTree.Root()), "snapshot %d", snapshot)
+		assert.Equal(t, accountTree.Root(), referenceAccountRoot(d, padWithZeroLeaves), "snapshot %d", snapshot)
+	}
+}
+
+// padding strategies for levels with an odd number of nodes
+const (
+	padWithZeroLeaves = iota
+	duplicateLastNode
+	promoteLastNode
+)
+
+// referenceRoot computes a keccak256 merkle root independently of the merkletree package
+func referenceRoot(leaves [][]byte, padding int) []byte {
+	nodes := make([][]byte, 0, len(leaves))
+	for _, leaf := range leaves {
+		nodes = append(nodes, crypto.Keccak256(leaf))
+	}
+	if padding == padWithZeroLeaves {
+		for len(nodes)&(len(nodes)-1) != 0 {
+			nodes = append(nodes, make([]byte, 32))
+		}
+	}
+
+	for len(nodes) > 1 {
+		next := make([][]byte, 0, (len(nodes)+1)/2)
+		for i := 0; i < len(nodes); i += 2 {
+			switch {
+			case i+1 < len(nodes):
+				next = append(next, crypto.Keccak256(nodes[i], nodes[i+1]))
+			case padding == duplicateLastNode:
+				next = append(next, crypto.Keccak256(nodes[i], nodes[i]))
+			default:
+				next = append(next, nodes[i])
+			}
+		}
+		nodes = next
+	}
+	return nodes[0]
+}
+
+// referenceAccountRoot computes the account root of a distribution with referenceRoot
+func referenceAccountRoot(d *distribution.Distribution, padding int) []byte {
+	accountLeaves := make([][]byte, 0)
+	for _, earner := range d.Earners() {
+		tokenLeaves := make([][]byte, 0)
+		for _, token := range d.TokensForEarner(earner) {
+			amount, _ := d.Get(earner, token)
+			tokenLeaves = append(tokenLeaves, distribution.EncodeTokenLeaf(token, amount))
+		}
+		accountLeaves = append(accountLeaves, distribution.EncodeAccountLeaf(earner, referenceRoot(tokenLeaves, padding)))
+	}
+	return referenceRoot(accountLeaves, padding)
+}
+
+func TestMerklizePadsWithZeroLeaves(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+
+	// 240 earners, so an unpadded tree would have a level of 15 nodes
+	assert.Len(t, d.Earners(), 240)
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotDistributionRoot, hex.EncodeToString(root))
+	assert.Equal(t, root, referenceAccountRoot(d, padWithZeroLeaves))
+
+	// 5 earners with 1 to 5 tokens
+	d = GetTestDistribution()
+	root, err = d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+	assert.Equal(t, root, referenceAccountRoot(d, padWithZeroLeaves))
+	assert.NotEqual(t, root, referenceAccountRoot(d, duplicateLastNode))
+	assert.NotEqual(t, root, referenceAccountRoot(d, promoteLastNode))
+}
+
+func TestAccountTreeNodes(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+
+	_, err = d.AccountTreeNodes()
+	assert.ErrorIs(t, err, distribution.ErrNotMerklized)
+
+	_, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+	root, err := d.Root()
+	assert.NoError(t, err)
+	nodes, err := d.AccountTreeNodes()
+	assert.NoError(t, err)
+
+	// the 240 earners are padded to 256 leaves
+	width := 256
+	assert.Len(t, nodes, 2*width-1)
+	for i, earner := range d.Earners() {
+		assert.Equal(t, distribution.HashLeaf(distribution.EncodeAccountLeaf(earner, tokenTrees[earner].Root())), nodes[i], "earner %s", earner.Hex())
+	}
+	for i := d.EarnerCount(); i < width; i++ {
+		assert.Equal(t, make([]byte, 32), nodes[i])
+	}
+	assert.Equal(t, root, nodes[len(nodes)-1])
+
+	// every branch is the hash of its two children in the level below
+	for offset := 0; width > 1; width /= 2 {
+		for i := 0; i < width/2; i++ {
+			assert.Equal(t, crypto.Keccak256(nodes[offset+2*i], nodes[offset+2*i+1]), nodes[offset+width+i])
+		}
+		offset += width
+	}
+
+	// the nodes are copies
+	nodes[len(nodes)-1][0] ^= 0xff
+	root, err = d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, snapshotDistributionRoot, hex.EncodeToString(root))
+
+	// a single earner is its hashed leaf
+	d = distribution.NewDistribution()
+	assert.NoError(t, d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1)))
+	root, err = d.Root()
+	assert.NoError(t, err)
+	nodes, err = d.AccountTreeNodes()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{root}, nodes)
+}
@@ -0,0 +1,112 @@
+package distribution
+
+import (
+	"fmt"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+)
+
+// snapshotIntervalMillis is the interval of the rewards snapshots, which are taken daily at midnight UTC
+const snapshotIntervalMillis = 24 * 60 * 60 * 1000
+
+// CheckSnapshotDayAligned returns ErrSnapshotNotDayAligned if a snapshot in milliseconds is not a whole
+// UTC day, which the rewards calculation never emits, so it points at a bug upstream rather than at a
+// snapshot that cannot be proven. The loaders accept any snapshot, this is checked by ValidateLines and
+// Validate only.
+func CheckSnapshotDayAligned(snapshot uint64) error {
+	if snapshot%snapshotIntervalMillis != 0 {
+		return fmt.Errorf("%w - snapshot: %d, %d ms past midnight", ErrSnapshotNotDayAligned, snapshot, snapshot%snapshotIntervalMillis)
+	}
+	return nil
+}
+
+// ValidateLines checks earner lines before they are loaded and returns every problem found rather
+// than stopping at the first: malformed addresses, unparseable, negative or overflowing amounts,
+// lines from a different snapshot than the first line, a first snapshot that is not at midnight UTC and
+// duplicate earner/token pairs. Lines are numbered from 1, like ParseError.LineNumber.
+// It returns nil if the lines are valid.
+func ValidateLines(lines []*EarnerLine) []error {
+	var errs []error
+	seen := make(map[gethcommon.Address]map[gethcommon.Address]int)
+	for i, line := range lines {
+		lineNumber := i + 1
+		validAddresses := true
+		for _, address := range []string{line.Earner, line.Token} {
+			if !gethcommon.IsHexAddress(address) {
+				errs = append(errs, fmt.Errorf("%w - line: %d, address: %q", ErrInvalidAddress, lineNumber, address))
+				validAddresses = false
+			}
+		}
+
+		amount, err := line.CumulativeAmountBigInt()
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("line: %d - %w", lineNumber, err))
+		case amount.Sign() < 0:
+			errs = append(errs, fmt.Errorf("%w - line: %d, amount: %s", ErrNegativeAmount, lineNumber, amount.String()))
+		case amount.BitLen() > 256:
+			errs = append(errs, fmt.Errorf("%w - line: %d, amount: %s", ErrAmountOverflow, lineNumber, amount.String()))
+		}
+
+		if line.Snapshot != lines[0].Snapshot {
+			errs = append(errs, fmt.Errorf("%w - line: %d, snapshot: %d, expected: %d", ErrSnapshotMismatch, lineNumber, line.Snapshot, lines[0].Snapshot))
+		} else if i == 0 {
+			if err := CheckSnapshotDayAligned(line.Snapshot); err != nil {
+				errs = append(errs, fmt.Errorf("line: %d - %w", lineNumber, err))
+			}
+		}
+
+		if !validAddresses {
+			continue
+		}
+		earner := gethcommon.HexToAddress(line.Earner)
+		token := gethcommon.HexToAddress(line.Token)
+		tokens, found := seen[earner]
+		if !found {
+			tokens = make(map[gethcommon.Address]int)
+			seen[earner] = tokens
+		}
+		if prev, found := tokens[token]; found {
+			errs = append(errs, fmt.Errorf("%w - lines: %d and %d, earner: %s, token: %s", ErrDuplicateEntry, prev, lineNumber, earner.Hex(), token.Hex()))
+			continue
+		}
+		tokens[token] = lineNumber
+	}
+	return errs
+}
+
+// Validate checks the loaded distribution before it is merklized and returns every problem found:
+// a Snapshot that is set but not at midnight UTC, amounts that are negative or do not fit in uint256
+// and earners or tokens that are out of order.
+// Set already rejects these, but amounts returned by Get may have been mutated since.
+// It returns nil if the distribution is valid.
+func (d *Distribution) Validate() []error {
+	var errs []error
+	if d.Snapshot != 0 {
+		if err := CheckSnapshotDayAligned(d.Snapshot); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for accountPair := d.data.Oldest(); accountPair != nil; accountPair = accountPair.Next() {
+		earner := accountPair.Key
+		if prev := accountPair.Prev(); prev != nil && CompareAddresses(prev.Key, earner) >= 0 {
+			errs = append(errs, fmt.Errorf("%w - prev: %s, earner: %s", ErrAddressNotInOrder, prev.Key.Hex(), earner.Hex()))
+		}
+
+		for tokenPair := accountPair.Value.Oldest(); tokenPair != nil; tokenPair = tokenPair.Next() {
+			token := tokenPair.Key
+			if prev := tokenPair.Prev(); prev != nil && CompareAddresses(prev.Key, token) >= 0 {
+				errs = append(errs, fmt.Errorf("%w - earner: %s, prev: %s, token: %s", ErrTokenNotInOrder, earner.Hex(), prev.Key.Hex(), token.Hex()))
+			}
+
+			amount := amountOrZero(tokenPair.Value)
+			if amount.Sign() < 0 {
+				errs = append(errs, fmt.Errorf("%w - earner: %s, token: %s, amount: %s", ErrNegativeAmount, earner.Hex(), token.Hex(), amount.String()))
+			}
+			if amount.BitLen() > 256 {
+				errs = append(errs, fmt.Errorf("%w - earner: %s, token: %s, amount: %s", ErrAmountOverflow, earner.Hex(), token.Hex(), amount.String()))
+			}
+		}
+	}
+	return errs
+}
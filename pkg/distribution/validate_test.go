@@ -0,0 +1,117 @@
+package distribution_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLines(t *testing.T) {
+	earner := tests.TestAddresses[0].Hex()
+	token := tests.TestTokens[0].Hex()
+	lines := []*distribution.EarnerLine{
+		{Earner: earner, Token: token, Snapshot: 1716681600000, CumulativeAmount: "1"},
+		{Earner: "0x1234", Token: token, Snapshot: 1716681600000, CumulativeAmount: "1"},
+		{Earner: earner, Token: tests.TestTokens[1].Hex(), Snapshot: 1716681600000, CumulativeAmount: "-1"},
+		{Earner: earner, Token: tests.TestTokens[2].Hex(), Snapshot: 1716681600000, CumulativeAmount: new(big.Int).Lsh(big.NewInt(1), 256).String()},
+		{Earner: tests.TestAddresses[1].Hex(), Token: token, Snapshot: 1712102400000, CumulativeAmount: "1"},
+		{Earner: earner, Token: token, Snapshot: 1716681600000, CumulativeAmount: "2"},
+		{Earner: tests.TestAddresses[2].Hex(), Token: "not an address", Snapshot: 1712102400000, CumulativeAmount: "1.5"},
+	}
+
+	errs := distribution.ValidateLines(lines)
+	expected := []struct {
+		err  error
+		line string
+	}{
+		{distribution.ErrInvalidAddress, "line: 2"},
+		{distribution.ErrNegativeAmount, "line: 3"},
+		{distribution.ErrAmountOverflow, "line: 4"},
+		{distribution.ErrSnapshotMismatch, "line: 5"},
+		{distribution.ErrDuplicateEntry, "lines: 1 and 6"},
+		{distribution.ErrInvalidAddress, "line: 7"},
+		{nil, "line: 7 - failed to parse cumulative reward, fractional amount: 1.5"},
+		{distribution.ErrSnapshotMismatch, "line: 7"},
+	}
+	assert.Len(t, errs, len(expected))
+	for i, e := range expected {
+		if i >= len(errs) {
+			break
+		}
+		if e.err != nil {
+			assert.ErrorIs(t, errs[i], e.err)
+		}
+		assert.ErrorContains(t, errs[i], e.line)
+	}
+}
+
+func TestValidateLinesValid(t *testing.T) {
+	lines := parseTestEarnerLines(t, getFullTestEarnerLines())
+	valid := make([]*distribution.EarnerLine, 0, len(lines))
+	for _, line := range lines {
+		if line.Snapshot == 1716681600000 {
+			valid = append(valid, line)
+		}
+	}
+
+	assert.Nil(t, distribution.ValidateLines(valid))
+	assert.NotNil(t, distribution.ValidateLines(lines))
+}
+
+func TestValidate(t *testing.T) {
+	d := GetTestDistribution()
+	assert.Nil(t, d.Validate())
+
+	// amounts returned by Get can be mutated past the bounds checked by Set
+	overflow, _ := d.Get(tests.TestAddresses[0], tests.TestTokens[1])
+	overflow.Lsh(overflow, 256)
+	negative, _ := d.Get(tests.TestAddresses[1], tests.TestTokens[0])
+	negative.Neg(negative)
+
+	errs := d.Validate()
+	assert.Len(t, errs, 2)
+	if len(errs) == 2 {
+		assert.ErrorIs(t, errs[0], distribution.ErrAmountOverflow)
+		assert.ErrorContains(t, errs[0], tests.TestTokens[1].Hex())
+		assert.ErrorIs(t, errs[1], distribution.ErrNegativeAmount)
+		assert.ErrorContains(t, errs[1], tests.TestAddresses[1].Hex())
+	}
+}
+
+func TestValidateSnapshotDayAligned(t *testing.T) {
+	for _, snapshot := range []uint64{1716681600000, 1716422400000, 1712102400000} {
+		assert.NoError(t, distribution.CheckSnapshotDayAligned(snapshot))
+	}
+	// an hour and a millisecond past midnight
+	for _, snapshot := range []uint64{1716681600000 + 3600000, 1716681600001} {
+		assert.ErrorIs(t, distribution.CheckSnapshotDayAligned(snapshot), distribution.ErrSnapshotNotDayAligned)
+	}
+
+	d := GetTestDistribution()
+	d.Snapshot = 1716681600000
+	assert.Nil(t, d.Validate())
+	d.Snapshot = 1716685200000
+	errs := d.Validate()
+	assert.Len(t, errs, 1)
+	if len(errs) == 1 {
+		assert.ErrorIs(t, errs[0], distribution.ErrSnapshotNotDayAligned)
+		assert.ErrorContains(t, errs[0], "3600000 ms past midnight")
+	}
+
+	// only the first line is checked, any other snapshot is reported as a mismatch
+	line := &distribution.EarnerLine{Earner: tests.TestAddresses[0].Hex(), Token: tests.TestTokens[0].Hex(), Snapshot: 1716685200000, CumulativeAmount: "1"}
+	errs = distribution.ValidateLines([]*distribution.EarnerLine{line, line})
+	assert.Len(t, errs, 2)
+	if len(errs) == 2 {
+		assert.ErrorIs(t, errs[0], distribution.ErrSnapshotNotDayAligned)
+		assert.ErrorContains(t, errs[0], "line: 1")
+		assert.ErrorIs(t, errs[1], distribution.ErrDuplicateEntry)
+	}
+
+	// the loaders still accept it
+	d = distribution.NewDistribution()
+	assert.NoError(t, d.LoadLines([]*distribution.EarnerLine{line}))
+}
@@ -0,0 +1,97 @@
+package distribution
+
+import (
+	"fmt"
+	"math/big"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
+)
+
+// Version selects the salts and layout of the leaves, so trees built for different versions of
+// the on-chain leaf format are never mixed.
+type Version uint8
+
+const (
+	// Version1 prefixes earner leaves with EarnerLeafSalt and token leaves with TokenLeafSalt
+	Version1 Version = 1
+
+	// CurrentVersion is the version used when Distribution.Version is not set
+	CurrentVersion = Version1
+)
+
+// leafFormat encodes the leaves of one version
+type leafFormat struct {
+	earnerLeafSalt byte
+	tokenLeafSalt  byte
+}
+
+var leafFormats = map[Version]leafFormat{
+	Version1: {earnerLeafSalt: EarnerLeafSalt, tokenLeafSalt: TokenLeafSalt},
+}
+
+// WithVersion sets the leaf format of the distribution, see Distribution.Version.
+func WithVersion(version Version) Option {
+	return func(d *Distribution) {
+		d.Version = version
+	}
+}
+
+// NewVersionedDistribution behaves like NewDistribution but encodes the leaves with the given
+// version, returning ErrUnknownVersion if it is not supported.
+func NewVersionedDistribution(version Version, opts ...Option) (*Distribution, error) {
+	if _, found := leafFormats[version]; !found {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return NewDistribution(append(opts, WithVersion(version))...), nil
+}
+
+// leafFormat returns the leaf format of the distribution's version, CurrentVersion when not set
+func (d *Distribution) leafFormat() (leafFormat, error) {
+	version := d.Version
+	if version == 0 {
+		version = CurrentVersion
+	}
+	format, found := leafFormats[version]
+	if !found {
+		return leafFormat{}, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return format, nil
+}
+
+// checkDomains returns ErrLeafDomain if a leaf of the format could be mistaken for a branch node of a tree
+// with the given hash length and arity. Nothing in the trees marks a node as a leaf or a branch: a verifier
+// tells them apart by what was hashed, a salted leaf or the concatenation of arity child hashes. With
+// keccak256 and an arity of 2 leaves are 53 bytes and branches 64, and the salts tell earner leaves from
+// token leaves, but a Hasher with a different length can make an account leaf, which holds a token root,
+// as long as a branch. The first bytes of the hashes do not matter, about 1 in 128 of them start with a
+// salt and they are never decoded as leaves.
+func (f leafFormat) checkDomains(hashLength, arity int) error {
+	if f.earnerLeafSalt == f.tokenLeafSalt {
+		return fmt.Errorf("%w - earner and token leaves have the same salt %d", ErrLeafDomain, f.earnerLeafSalt)
+	}
+	branchLength := arity * hashLength
+	tokenLeafLength := 1 + gethcommon.AddressLength + 32
+	accountLeafLength := 1 + gethcommon.AddressLength + hashLength
+	if tokenLeafLength == branchLength || accountLeafLength == branchLength {
+		return fmt.Errorf("%w - branches of %d hashes of %d bytes are as long as a leaf", ErrLeafDomain, arity, hashLength)
+	}
+	return nil
+}
+
+// precondition: accountRoot must be 32 bytes
+func (f leafFormat) encodeAccountLeaf(account gethcommon.Address, accountRoot []byte) []byte {
+	// (earnerLeafSalt || account || accountRoot)
+	return append([]byte{f.earnerLeafSalt}, append(account.Bytes(), accountRoot[:]...)...)
+}
+
+func (f leafFormat) encodeTokenLeaf(token gethcommon.Address, amount *big.Int) []byte {
+	// todo: handle this better
+	amountU256, _ := uint256.FromBig(amount)
+	return f.encodeTokenLeafBytes32(token, amountU256.Bytes32())
+}
+
+func (f leafFormat) encodeTokenLeafBytes32(token gethcommon.Address, amount [32]byte) []byte {
+	// (tokenLeafSalt || token || amount)
+	return append([]byte{f.tokenLeafSalt}, append(token.Bytes(), amount[:]...)...)
+}
@@ -0,0 +1,146 @@
+package distribution_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/stretchr/testify/assert"
+	"github.com/wealdtech/go-merkletree/v2"
+)
+
+func TestNewVersionedDistribution(t *testing.T) {
+	d, err := distribution.NewVersionedDistribution(distribution.CurrentVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, distribution.Version1, d.Version)
+	for i := 0; i < len(tests.TestAddresses); i++ {
+		for j := 0; j < len(tests.TestTokens)-i; j++ {
+			err := d.Set(tests.TestAddresses[i], tests.TestTokens[j], big.NewInt(int64(j+i+1)))
+			assert.NoError(t, err)
+		}
+	}
+
+	root, err := d.Root()
+	assert.NoError(t, err)
+	assert.Equal(t, testDistributionRoot, hex.EncodeToString(root))
+
+	leaf, found := d.TokenLeaf(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.True(t, found)
+	assert.Equal(t, distribution.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(1)), leaf)
+
+	tokenLeaf, err := d.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, leaf, tokenLeaf)
+	accountLeaf, err := d.EncodeAccountLeaf(tests.TestAddresses[0], root)
+	assert.NoError(t, err)
+	assert.Equal(t, distribution.EncodeAccountLeaf(tests.TestAddresses[0], root), accountLeaf)
+
+	_, err = distribution.NewVersionedDistribution(distribution.Version(2))
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+	_, err = distribution.NewVersionedDistribution(distribution.Version(0))
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+}
+
+func TestNewDistributionUnknownVersion(t *testing.T) {
+	d := distribution.NewDistribution(distribution.WithVersion(distribution.Version(2)))
+	err := d.Set(tests.TestAddresses[0], tests.TestTokens[0], big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+	err = d.LoadLines(parseTestEarnerLines(t, getFullTestEarnerLines())[:1])
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+	assert.Zero(t, d.Len())
+
+	_, err = d.EncodeTokenLeaf(tests.TestTokens[0], big.NewInt(1))
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+	_, err = d.EncodeAccountLeaf(tests.TestAddresses[0], make([]byte, 32))
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+}
+
+func TestUnknownVersion(t *testing.T) {
+	d := GetTestDistribution()
+	d.Version = distribution.Version(2)
+
+	_, _, err := d.Merklize()
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+	_, err = d.ComputeRoot()
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+	_, found := d.TokenLeaf(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.False(t, found)
+
+	// trees built for one version are not loaded into another
+	other := GetTestDistribution()
+	_, _, err = other.Merklize()
+	assert.NoError(t, err)
+	trees, err := other.MarshalTrees()
+	assert.NoError(t, err)
+	err = d.LoadTrees(trees)
+	assert.ErrorIs(t, err, distribution.ErrUnknownVersion)
+}
+
+func TestLeafDomainSeparation(t *testing.T) {
+	d := distribution.NewDistribution()
+	err := d.LoadLinesForSnapshot(parseTestEarnerLines(t, getFullTestEarnerLines()), 1716681600000)
+	assert.NoError(t, err)
+	accountTree, tokenTrees, err := d.Merklize()
+	assert.NoError(t, err)
+
+	saltPrefixed := 0
+	checkTree := func(tree *merkletree.MerkleTree, salt byte) {
+		for _, leaf := range tree.Data {
+			assert.Len(t, leaf, 53)
+			assert.Equal(t, salt, leaf[0])
+		}
+		// every branch is hashed from the 64 bytes of its children, never from something as long as a leaf
+		leavesOffset := len(tree.Nodes) / 2
+		for i := 1; i < leavesOffset; i++ {
+			preimage := append(append([]byte{}, tree.Nodes[2*i]...), tree.Nodes[2*i+1]...)
+			assert.Len(t, preimage, 64)
+			assert.Equal(t, crypto.Keccak256(preimage), tree.Nodes[i])
+		}
+		for _, node := range tree.Nodes[1:] {
+			if node[0] == distribution.EarnerLeafSalt || node[0] == distribution.TokenLeafSalt {
+				saltPrefixed++
+			}
+		}
+	}
+	checkTree(accountTree, distribution.EarnerLeafSalt)
+	for _, tokenTree := range tokenTrees {
+		checkTree(tokenTree, distribution.TokenLeafSalt)
+	}
+
+	// hashes starting with a salt byte are expected and harmless, they are never decoded as leaves
+	assert.Greater(t, saltPrefixed, 0)
+}
+
+type truncatedHasher struct {
+	length int
+}
+
+func (h truncatedHasher) Hash(data []byte) []byte {
+	return crypto.Keccak256(data)[:h.length]
+}
+
+func TestLeafDomainHasher(t *testing.T) {
+	// an account leaf of 1 + 20 + 21 bytes is as long as a branch of two 21 byte hashes
+	d := getTestDistributionWithOptions(distribution.WithHasher(truncatedHasher{length: 21}))
+	_, _, err := d.Merklize()
+	assert.ErrorIs(t, err, distribution.ErrLeafDomain)
+	_, err = d.ComputeRoot()
+	assert.ErrorIs(t, err, distribution.ErrLeafDomain)
+
+	// and one of 1 + 20 + 7 bytes as long as a branch of four 7 byte hashes
+	d = getTestDistributionWithOptions(distribution.WithHasher(truncatedHasher{length: 7}), distribution.WithArity(4))
+	_, err = d.ComputeRoot()
+	assert.ErrorIs(t, err, distribution.ErrLeafDomain)
+	_, err = d.GenerateArityProof(tests.TestAddresses[0], tests.TestTokens[0])
+	assert.ErrorIs(t, err, distribution.ErrLeafDomain)
+
+	// other lengths are fine
+	for _, length := range []int{7, 20, 32} {
+		d = getTestDistributionWithOptions(distribution.WithHasher(truncatedHasher{length: length}))
+		_, _, err = d.Merklize()
+		assert.NoError(t, err, "length %d", length)
+	}
+}
@@ -3,8 +3,8 @@ package proofDataFetcher
 import (
 	"context"
 	"encoding/json"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/wealdtech/go-merkletree/v2"
 	"net/http"
 	"strconv"
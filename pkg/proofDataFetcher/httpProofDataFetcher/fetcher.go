@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/distribution"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/proofDataFetcher"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/utils"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/proofDataFetcher"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/utils"
 	"io"
 	"net/http"
 	"strings"
@@ -2,8 +2,10 @@ package httpProofDataFetcher
 
 import (
 	"context"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/internal/tests"
+	"fmt"
 	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/internal/tests"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/distribution"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
@@ -73,7 +75,7 @@ func TestHttpProofDataFetcher_FetchClaimAmountsForDate(t *testing.T) {
 		mockDo: func(r *http.Request) *http.Response {
 			return &http.Response{
 				StatusCode: 200,
-				Body:       io.NopCloser(strings.NewReader(tests.GetFullTestEarnerLines())),
+				Body:       io.NopCloser(strings.NewReader(getTestEarnerLinesForSnapshot(1716681600000))),
 			}
 		},
 	}
@@ -96,3 +98,32 @@ func TestHttpProofDataFetcher_FetchClaimAmountsForDate(t *testing.T) {
 	assert.True(t, found)
 	assert.Equal(t, "2690822690822645700000000000", amount.String())
 }
+
+func TestHttpProofDataFetcher_FetchClaimAmountsForDateMixedSnapshots(t *testing.T) {
+	mockClient := &mockHttpClient{
+		mockDo: func(r *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       io.NopCloser(strings.NewReader(tests.GetFullTestEarnerLines())),
+			}
+		},
+	}
+
+	fetcher := NewHttpProofDataFetcher("https://eigenpayments-dev.s3.us-east-2.amazonaws.com", "preprod", "holesky", mockClient)
+
+	proofData, err := fetcher.FetchClaimAmountsForDate(context.Background(), "2024-05-07")
+	assert.ErrorIs(t, err, distribution.ErrSnapshotMismatch)
+	assert.Nil(t, proofData)
+}
+
+// getTestEarnerLinesForSnapshot returns the test earner lines from a single snapshot
+func getTestEarnerLinesForSnapshot(snapshot uint64) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(tests.GetFullTestEarnerLines(), "\n") {
+		if strings.Contains(line, fmt.Sprintf(`"snapshot":%d,`, snapshot)) {
+			sb.WriteString(line)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
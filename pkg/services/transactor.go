@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	rewardsCoordinator "github.com/Layr-Labs/eigenlayer-contracts/pkg/bindings/IRewardsCoordinator"
-	"github.com/Layr-Labs/eigenlayer-rewards-proofs/pkg/chainClient"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/etherfi-protocol/eigenlayer-rewards-proofs/pkg/chainClient"
 	"math/big"
 )
 